@@ -0,0 +1,126 @@
+package glance
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// admission limits the number of simultaneously running workers of the workspace.
+// Waiting tasks are admitted by priority, and in the order of arrival within the same priority
+type admission struct {
+	mu       sync.Mutex
+	limit    int
+	running  int
+	sequence uint64
+	queue    waiters
+}
+
+type waiter struct {
+	priority int
+	sequence uint64
+	index    int
+	ready    chan struct{}
+}
+
+func newAdmission(limit int) *admission {
+	return &admission{limit: limit}
+}
+
+// acquire takes a slot for launching the worker, waiting in the queue if all slots are taken,
+// onQueue is called when the task is placed in the queue. Returns false if the context was completed earlier
+func (a *admission) acquire(ctx context.Context, priority int, onQueue func()) bool {
+	if a.limit <= 0 {
+		return true
+	}
+
+	a.mu.Lock()
+	if a.running < a.limit && a.queue.Len() == 0 {
+		a.running++
+		a.mu.Unlock()
+
+		return true
+	}
+
+	a.sequence++
+	w := &waiter{priority: priority, sequence: a.sequence, ready: make(chan struct{})}
+	heap.Push(&a.queue, w)
+	a.mu.Unlock()
+
+	onQueue()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-ctx.Done():
+		a.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&a.queue, w.index)
+			a.mu.Unlock()
+
+			return false
+		}
+		a.mu.Unlock()
+
+		// the slot was granted at the same time as the context was completed, give it back
+		a.release()
+
+		return false
+	}
+}
+
+// release frees the slot and admits the following tasks from the queue
+func (a *admission) release() {
+	if a.limit <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.running--
+	for a.running < a.limit && a.queue.Len() > 0 {
+		w, _ := heap.Pop(&a.queue).(*waiter)
+		a.running++
+		close(w.ready)
+	}
+}
+
+// waiters implements heap.Interface, tasks with a higher priority go first,
+// with the same priority those that came earlier
+type waiters []*waiter
+
+func (q waiters) Len() int {
+	return len(q)
+}
+
+func (q waiters) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+
+	return q[i].sequence < q[j].sequence
+}
+
+func (q waiters) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiters) Push(x interface{}) {
+	w, _ := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiters) Pop() interface{} {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+
+	return w
+}
@@ -0,0 +1,99 @@
+package glance
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultSubscriberBuffer = 100
+
+// EventType the type of the lifecycle event of the workspace
+type EventType string
+
+const (
+	EventTaskStarted      EventType = "task_started"
+	EventTaskExited       EventType = "task_exited"
+	EventTaskCrashed      EventType = "task_crashed"
+	EventTaskStalled      EventType = "task_stalled"
+	EventTaskOverBudget   EventType = "task_over_budget"
+	EventTaskStopped      EventType = "task_stopped"
+	EventRestartScheduled EventType = "restart_scheduled"
+	EventWorkspaceDrained EventType = "workspace_drained"
+)
+
+// Event the lifecycle event of the task or the workspace,
+// fields that are not related to the type of the event are left empty
+type Event struct {
+	Type      EventType
+	Workspace string
+	StreamID  string
+	Time      time.Time
+	// State of the task after the event
+	State TaskState
+	// Attempt the number of the launch of the task worker
+	Attempt int
+	// Error and ExitCode the reason for the completion of the worker
+	Error    error
+	ExitCode int
+	// Stack the stack trace of the crashed worker
+	Stack string
+	// Delay before the scheduled restart
+	Delay time.Duration
+}
+
+// EventBus delivers events to all subscribers without blocking the publisher,
+// if the subscriber does not have time to read the events, new events for it are dropped
+type EventBus struct {
+	mu          sync.RWMutex
+	sequence    uint64
+	subscribers map[uint64]chan Event
+}
+
+func NewEventBus() *EventBus {
+	bus := &EventBus{subscribers: map[uint64]chan Event{}}
+	return bus
+}
+
+// Subscribe returns a channel of events with the specified buffer size and the function to unsubscribe,
+// after which the channel is closed
+func (b *EventBus) Subscribe(buffer int) (events <-chan Event, unsubscribe func()) {
+	if buffer <= 0 {
+		buffer = defaultSubscriberBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sequence++
+	id := b.sequence
+	channel := make(chan Event, buffer)
+	b.subscribers[id] = channel
+
+	once := sync.Once{}
+	return channel, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers, id)
+			close(channel)
+		})
+	}
+}
+
+// Publish sends the event to all subscribers
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, channel := range b.subscribers {
+		select {
+		case channel <- event:
+		default:
+		}
+	}
+}
@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/ClickHouse/clickhouse-go v1.4.8 // indirect
 	github.com/doug-martin/goqu/v9 v9.18.0
-	github.com/jmoiron/sqlx v1.3.4 // indirect
+	github.com/jmoiron/sqlx v1.3.4
 	github.com/zikwall/clickhouse-buffer v0.0.2
+	gopkg.in/yaml.v3 v3.0.1
 )
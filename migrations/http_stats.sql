@@ -5,7 +5,8 @@ CREATE TABLE stream.http_status ON CLUSTER cluster_1
     `stream_id`   String,
     `code`        Int32,
     `insert_ts`   DateTime,
-    `insert_date` Date
+    `insert_date` Date,
+    `labels`      Nested(name String, value String)
 )
     ENGINE = Distributed('cluster_1', 'stream', 'http_status_sharded', rand());
 
@@ -14,7 +15,8 @@ CREATE TABLE stream.http_status_sharded ON CLUSTER cluster_1
     `stream_id`   String,
     `code`        Int32,
     `insert_ts`   DateTime,
-    `insert_date` Date
+    `insert_date` Date,
+    `labels`      Nested(name String, value String)
 )
     ENGINE = ReplicatedMergeTree('/clickhouse/tables/stream/{shard}/http_status_sharded', '{replica}')
         PARTITION BY toYYYYMM(insert_date)
@@ -0,0 +1,17 @@
+ALTER TABLE stream.metrics_sharded ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `has_audio` UInt8 AFTER `keyframe_interval`,
+    ADD COLUMN IF NOT EXISTS `audio_sample_rate` UInt64 AFTER `has_audio`,
+    ADD COLUMN IF NOT EXISTS `audio_channels` UInt64 AFTER `audio_sample_rate`,
+    ADD COLUMN IF NOT EXISTS `audio_bitrate` Float64 AFTER `audio_channels`,
+    ADD COLUMN IF NOT EXISTS `audio_frames` UInt64 AFTER `audio_bitrate`,
+    ADD COLUMN IF NOT EXISTS `audio_gaps` UInt64 AFTER `audio_frames`,
+    ADD COLUMN IF NOT EXISTS `audio_gap_seconds` Float64 AFTER `audio_gaps`;
+
+ALTER TABLE stream.metrics ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `has_audio` UInt8 AFTER `keyframe_interval`,
+    ADD COLUMN IF NOT EXISTS `audio_sample_rate` UInt64 AFTER `has_audio`,
+    ADD COLUMN IF NOT EXISTS `audio_channels` UInt64 AFTER `audio_sample_rate`,
+    ADD COLUMN IF NOT EXISTS `audio_bitrate` Float64 AFTER `audio_channels`,
+    ADD COLUMN IF NOT EXISTS `audio_frames` UInt64 AFTER `audio_bitrate`,
+    ADD COLUMN IF NOT EXISTS `audio_gaps` UInt64 AFTER `audio_frames`,
+    ADD COLUMN IF NOT EXISTS `audio_gap_seconds` Float64 AFTER `audio_gaps`;
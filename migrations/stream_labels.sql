@@ -0,0 +1,11 @@
+ALTER TABLE stream.metrics_sharded ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `labels` Nested(name String, value String);
+
+ALTER TABLE stream.metrics ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `labels` Nested(name String, value String);
+
+ALTER TABLE stream.http_status_sharded ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `labels` Nested(name String, value String);
+
+ALTER TABLE stream.http_status ON CLUSTER cluster_1
+    ADD COLUMN IF NOT EXISTS `labels` Nested(name String, value String);
@@ -10,8 +10,16 @@ CREATE TABLE stream.metrics ON CLUSTER cluster_1
     `bytes`             UInt64,
     `seconds`           Float64,
     `keyframe_interval` UInt64,
+    `has_audio`         UInt8,
+    `audio_sample_rate` UInt64,
+    `audio_channels`    UInt64,
+    `audio_bitrate`     Float64,
+    `audio_frames`      UInt64,
+    `audio_gaps`        UInt64,
+    `audio_gap_seconds` Float64,
     `insert_ts`         DateTime,
-    `date`              Date
+    `date`              Date,
+    `labels`            Nested(name String, value String)
 )
     ENGINE = Distributed('cluster_1', 'stream', 'metrics_sharded', rand());
 
@@ -25,8 +33,16 @@ CREATE TABLE stream.metrics_sharded ON CLUSTER cluster_1
     `bytes`             UInt64,
     `seconds`           Float64,
     `keyframe_interval` UInt64,
+    `has_audio`         UInt8,
+    `audio_sample_rate` UInt64,
+    `audio_channels`    UInt64,
+    `audio_bitrate`     Float64,
+    `audio_frames`      UInt64,
+    `audio_gaps`        UInt64,
+    `audio_gap_seconds` Float64,
     `insert_ts`         DateTime,
-    `date`              Date
+    `date`              Date,
+    `labels`            Nested(name String, value String)
 )
     ENGINE = ReplicatedMergeTree('/clickhouse/tables/stream/{shard}/metrics_sharded', '{replica}')
         PARTITION BY toYYYYMM(date)
@@ -0,0 +1,24 @@
+CREATE TABLE stream.stalls ON CLUSTER cluster_1
+(
+    `stream_id` String,
+    `workspace` String,
+    `silence`   Float64,
+    `attempt`   UInt64,
+    `insert_ts` DateTime,
+    `date`      Date
+)
+    ENGINE = Distributed('cluster_1', 'stream', 'stalls_sharded', rand());
+
+CREATE TABLE stream.stalls_sharded ON CLUSTER cluster_1
+(
+    `stream_id` String,
+    `workspace` String,
+    `silence`   Float64,
+    `attempt`   UInt64,
+    `insert_ts` DateTime,
+    `date`      Date
+)
+    ENGINE = ReplicatedMergeTree('/clickhouse/tables/stream/{shard}/stalls_sharded', '{replica}')
+        PARTITION BY toYYYYMM(date)
+        ORDER BY (stream_id, date)
+        TTL insert_ts + INTERVAL 12 MONTH;
\ No newline at end of file
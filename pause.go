@@ -0,0 +1,169 @@
+package glance
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/workers/errorless"
+)
+
+// pause the stream that is temporarily not monitored, for example during planned maintenance
+type pause struct {
+	reason   string
+	pausedAt time.Time
+	// until the pause expires automatically, zero means that the pause lasts until Resume
+	until time.Time
+	// stream the descriptor of the stopped task, which is started again on Resume
+	stream WorkerStream
+	// exited is closed when the stopped task has exited, nil if the stream was paused outside the pool
+	exited <-chan struct{}
+	timer  *time.Timer
+}
+
+func (p *pause) stop() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// PauseInfo the paused stream of the workspace
+type PauseInfo struct {
+	ID       string `json:"id"`
+	Reason   string `json:"reason"`
+	PausedAt string `json:"paused_at"`
+	Until    string `json:"until,omitempty"`
+}
+
+// Pause stops the task of the stream without forgetting it, the stream can be paused even if it is not in the pool.
+// While the stream is paused, PerformAsync refuses it, so the scheduler does not start it on the next refresh.
+// The pause expires at until, the zero time means that the pause lasts until Resume
+func (w *Workspace) Pause(id, reason string, until time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.context.Err() != nil {
+		return ErrorWorkspaceIsDrained
+	}
+
+	// the repeated pause replaces the previous one, keeping the stopped task
+	p := &pause{reason: reason, pausedAt: time.Now(), until: until}
+	if previous, ok := w.pauses[id]; ok {
+		previous.stop()
+		p.stream = previous.stream
+		p.exited = previous.exited
+	}
+	w.pauses[id] = p
+
+	if process, ok := w.tasks[id]; ok {
+		p.stream = process.stream
+		p.exited = process.exited
+		w.pushTransition(id, process, TaskPaused, nil)
+		process.cancel()
+		delete(w.tasks, id)
+	}
+
+	if !until.IsZero() {
+		p.timer = time.AfterFunc(time.Until(until), func() {
+			w.expire(id, p)
+		})
+	}
+
+	w.pauseAsyncTaskMsg(id, reason, until)
+
+	return nil
+}
+
+// Resume removes the pause of the stream and starts its task again, if it was stopped by Pause.
+// Resume waits until the stopped task has exited, so that two workers of the same stream never run at once
+func (w *Workspace) Resume(id string) error {
+	p, ok := w.resumeIf(id, nil)
+	if !ok {
+		return errorless.TaskNotFound(id)
+	}
+
+	return w.restart(id, p)
+}
+
+// resumeIf removes the pause of the stream, if it is the expected one or the expected pause is nil,
+// returns the removed pause. The check and the removal are performed under the same lock,
+// so the expired timer of the replaced pause can not remove the new one
+func (w *Workspace) resumeIf(id string, expected *pause) (*pause, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, ok := w.pauses[id]
+	if !ok || (expected != nil && p != expected) {
+		return nil, false
+	}
+
+	p.stop()
+	delete(w.pauses, id)
+
+	return p, true
+}
+
+// restart starts the task of the resumed stream after the stopped task has exited
+func (w *Workspace) restart(id string, p *pause) error {
+	log.Info(errorless.Labeled(w.worker.Name(), fmt.Sprintf("[#%s] resumed", id)))
+
+	if p.stream == nil {
+		return nil
+	}
+
+	// the stopped task may still be terminating its children
+	if p.exited != nil {
+		<-p.exited
+	}
+
+	return w.PerformAsync(p.stream)
+}
+
+// IsPaused reports whether the stream is paused
+func (w *Workspace) IsPaused(id string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	_, ok := w.pauses[id]
+	return ok
+}
+
+// expire resumes the stream, if the pause has not been changed or removed in the meantime
+func (w *Workspace) expire(id string, p *pause) {
+	if _, ok := w.resumeIf(id, p); !ok {
+		return
+	}
+
+	if err := w.restart(id, p); err != nil {
+		errorless.Warning(w.worker.Name(), fmt.Sprintf("[#%s] failed to resume after the pause: %s", id, err))
+	}
+}
+
+// pausedInformation returns the paused streams sorted by ID, the caller must hold the lock
+func (w *Workspace) pausedInformation() []PauseInfo {
+	paused := make([]PauseInfo, 0, len(w.pauses))
+	for id, p := range w.pauses {
+		info := PauseInfo{ID: id, Reason: p.reason, PausedAt: Datetime(p.pausedAt)}
+		if !p.until.IsZero() {
+			info.Until = Datetime(p.until)
+		}
+
+		paused = append(paused, info)
+	}
+
+	sort.Slice(paused, func(i, j int) bool {
+		return paused[i].ID < paused[j].ID
+	})
+
+	return paused
+}
+
+func (w *Workspace) pauseAsyncTaskMsg(id, reason string, until time.Time) {
+	expires := "until resume"
+	if !until.IsZero() {
+		expires = fmt.Sprintf("until %s", Datetime(until))
+	}
+
+	log.Info(errorless.Labeled(w.worker.Name(), fmt.Sprintf("[#%s] paused %s, reason: %s", id, expires, reason)))
+}
@@ -0,0 +1,271 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/workers/errorless"
+)
+
+// Handler JSON administration API of the workstation, it can be mounted into any mux:
+//
+// mux.Handle("/glance/", http.StripPrefix("/glance", admin.NewHandler(workstation)))
+//
+// GET    /info                          full information about the workstation
+// GET    /runtime                       runtime information
+// GET    /workspaces                    list of workspaces and their tasks
+// GET    /workspaces/{name}             single workspace and its tasks
+// GET    /workspaces/{name}/tasks/{id}  single task, including detached from the pool
+// POST   /workspaces/{name}/tasks       force start of the task, body {"id": "...", "url": "...", "priority": 0}
+// DELETE /workspaces/{name}/tasks/{id}  stop the task
+// POST   /workspaces/{name}/tasks/{id}/pause   pause the stream, body {"reason": "...", "until": "2006-01-02T15:04:05Z"}
+// POST   /workspaces/{name}/tasks/{id}/resume  resume the paused stream
+type Handler struct {
+	workstation *glance.Workstation
+}
+
+func NewHandler(workstation *glance.Workstation) *Handler {
+	handler := &Handler{workstation: workstation}
+	return handler
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type taskRequest struct {
+	ID       string            `json:"id"`
+	URL      string            `json:"url"`
+	Priority int               `json:"priority"`
+	Labels   map[string]string `json:"labels"`
+	Headers  map[string]string `json:"headers"`
+	Options  map[string]string `json:"options"`
+}
+
+// nolint:gocyclo // its OK, simple routing table
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(segments) == 1 && segments[0] == "info":
+		h.get(w, r, func() (interface{}, error) {
+			return h.workstation.WorkstationInformation(), nil
+		})
+	case len(segments) == 1 && segments[0] == "runtime":
+		h.get(w, r, func() (interface{}, error) {
+			return h.workstation.RuntimeInformation(), nil
+		})
+	case len(segments) == 1 && segments[0] == "workspaces":
+		h.get(w, r, func() (interface{}, error) {
+			return h.workstation.WorkstationInformation().Workspaces, nil
+		})
+	case len(segments) == 2 && segments[0] == "workspaces":
+		h.get(w, r, func() (interface{}, error) {
+			workspace, err := h.workstation.Workspace(segments[1])
+			if err != nil {
+				return nil, notFound(err)
+			}
+
+			return workspace.Information(), nil
+		})
+	case len(segments) == 3 && segments[0] == "workspaces" && segments[2] == "tasks":
+		h.startTask(w, r, segments[1])
+	case len(segments) == 4 && segments[0] == "workspaces" && segments[2] == "tasks":
+		h.task(w, r, segments[1], segments[3])
+	case len(segments) == 5 && segments[0] == "workspaces" && segments[2] == "tasks" && segments[4] == "pause":
+		h.pauseTask(w, r, segments[1], segments[3])
+	case len(segments) == 5 && segments[0] == "workspaces" && segments[2] == "tasks" && segments[4] == "resume":
+		h.resumeTask(w, r, segments[1], segments[3])
+	default:
+		respond(w, http.StatusNotFound, errorResponse{Error: "route not found"})
+	}
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, fn func() (interface{}, error)) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+
+	value, err := fn()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, http.StatusOK, value)
+}
+
+func (h *Handler) startTask(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	workspace, err := h.workstation.Workspace(name)
+	if err != nil {
+		respondError(w, notFound(err))
+		return
+	}
+
+	task := taskRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		respond(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if task.ID == "" || task.URL == "" {
+		respond(w, http.StatusBadRequest, errorResponse{Error: "id and url are required"})
+		return
+	}
+
+	stream := glance.WorkerItem{
+		ID:       task.ID,
+		URL:      task.URL,
+		Priority: task.Priority,
+		Labels:   task.Labels,
+		Headers:  task.Headers,
+		Options:  task.Options,
+	}
+
+	if err := workspace.PerformAsync(stream); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	info, _ := workspace.TaskInformation(task.ID)
+	respond(w, http.StatusCreated, info)
+}
+
+func (h *Handler) task(w http.ResponseWriter, r *http.Request, name, id string) {
+	workspace, err := h.workstation.Workspace(name)
+	if err != nil {
+		respondError(w, notFound(err))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, ok := workspace.TaskInformation(id)
+		if !ok {
+			respondError(w, errorless.TaskNotFound(id))
+			return
+		}
+
+		respond(w, http.StatusOK, info)
+	case http.MethodDelete:
+		if err := workspace.FinishAsyncTask(id); err != nil {
+			respondError(w, err)
+			return
+		}
+
+		info, _ := workspace.TaskInformation(id)
+		respond(w, http.StatusOK, info)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+type pauseRequest struct {
+	Reason string `json:"reason"`
+	// Until the time of the expiration of the pause, the pause lasts until resume if it is not set
+	Until time.Time `json:"until"`
+}
+
+func (h *Handler) pauseTask(w http.ResponseWriter, r *http.Request, name, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	workspace, err := h.workstation.Workspace(name)
+	if err != nil {
+		respondError(w, notFound(err))
+		return
+	}
+
+	pause := pauseRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&pause); err != nil {
+		respond(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workspace.Pause(id, pause.Reason, pause.Until); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, http.StatusOK, workspace.Information().Paused)
+}
+
+func (h *Handler) resumeTask(w http.ResponseWriter, r *http.Request, name, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	workspace, err := h.workstation.Workspace(name)
+	if err != nil {
+		respondError(w, notFound(err))
+		return
+	}
+
+	if err := workspace.Resume(id); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	info, _ := workspace.TaskInformation(id)
+	respond(w, http.StatusOK, info)
+}
+
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func notFound(err error) error {
+	return &notFoundError{err: err}
+}
+
+func respondError(w http.ResponseWriter, err error) {
+	var (
+		notFound      *notFoundError
+		taskNotFound  *errorless.TaskNotFoundError
+		alreadyExists *errorless.TaskAlreadyExistsError
+		paused        *errorless.TaskPausedError
+	)
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &taskNotFound):
+		status = http.StatusNotFound
+	case errors.As(err, &alreadyExists), errors.As(err, &paused):
+		status = http.StatusConflict
+	case errors.Is(err, glance.ErrorWorkspaceIsDrained):
+		status = http.StatusServiceUnavailable
+	}
+
+	respond(w, status, errorResponse{Error: err.Error()})
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	respond(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+}
+
+func respond(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Warning(err)
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+type MockWorker struct{}
+
+func (w *MockWorker) Perform(ctx context.Context, _ glance.WorkerStream) {
+	<-ctx.Done()
+}
+
+func (w *MockWorker) Name() string {
+	return "mock_worker"
+}
+
+func (w *MockWorker) Label() string {
+	return "mock_worker/"
+}
+
+func TestHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := http.NewServeMux()
+	mux.Handle("/glance/", http.StripPrefix("/glance", NewHandler(glance.New(ctx, &MockWorker{}))))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	request := func(method, path, body string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	expect := func(res *http.Response, status int, value interface{}) {
+		defer func() {
+			_ = res.Body.Close()
+		}()
+
+		if res.StatusCode != status {
+			t.Fatalf("Failed, expect HTTP code %d, give %d", status, res.StatusCode)
+		}
+
+		if value == nil {
+			return
+		}
+
+		if err := json.NewDecoder(res.Body).Decode(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("it should be force start task", func(t *testing.T) {
+		info := glance.ProcessInfo{}
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks", `{"id":"1","url":"rtmp://localhost/1"}`),
+			http.StatusCreated, &info,
+		)
+
+		if info.ID != "1" {
+			t.Fatalf("Failed, expect task #1, give '%s'", info.ID)
+		}
+
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks", `{"id":"1","url":"rtmp://localhost/1"}`),
+			http.StatusConflict, nil,
+		)
+	})
+
+	t.Run("it should be list workspaces and inspect task", func(t *testing.T) {
+		workspaces := map[string]glance.WorkspaceInfo{}
+		expect(request(http.MethodGet, "/glance/workspaces", ""), http.StatusOK, &workspaces)
+
+		if workspaces["mock_worker"].TotalProcesses != 1 {
+			t.Fatal("Failed, expect one process in workspace")
+		}
+
+		info := glance.ProcessInfo{}
+		expect(request(http.MethodGet, "/glance/workspaces/mock_worker/tasks/1", ""), http.StatusOK, &info)
+
+		expect(request(http.MethodGet, "/glance/workspaces/unknown", ""), http.StatusNotFound, nil)
+		expect(request(http.MethodGet, "/glance/runtime", ""), http.StatusOK, &glance.RuntimeInfo{})
+	})
+
+	t.Run("it should be stop task", func(t *testing.T) {
+		expect(request(http.MethodDelete, "/glance/workspaces/mock_worker/tasks/1", ""), http.StatusOK, nil)
+
+		<-time.After(time.Millisecond * 50)
+
+		info := glance.ProcessInfo{}
+		expect(request(http.MethodGet, "/glance/workspaces/mock_worker/tasks/1", ""), http.StatusOK, &info)
+
+		if info.State != glance.TaskStoppedByScheduler {
+			t.Fatalf("Failed, expect stopped task, give %s", info.State)
+		}
+
+		expect(request(http.MethodDelete, "/glance/workspaces/mock_worker/tasks/1", ""), http.StatusNotFound, nil)
+	})
+	t.Run("it should be pause and resume task", func(t *testing.T) {
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks", `{"id":"2","url":"rtmp://localhost/2"}`),
+			http.StatusCreated, nil,
+		)
+
+		paused := []glance.PauseInfo{}
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks/2/pause", `{"reason":"maintenance"}`),
+			http.StatusOK, &paused,
+		)
+
+		if len(paused) != 1 || paused[0].Reason != "maintenance" {
+			t.Fatalf("Failed, expect paused task #2, give %+v", paused)
+		}
+
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks", `{"id":"2","url":"rtmp://localhost/2"}`),
+			http.StatusConflict, nil,
+		)
+
+		info := glance.ProcessInfo{}
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks/2/resume", ""), http.StatusOK, &info)
+
+		if info.State == glance.TaskPaused {
+			t.Fatal("Failed, expect resumed task")
+		}
+
+		expect(request(http.MethodPost, "/glance/workspaces/mock_worker/tasks/2/resume", ""), http.StatusNotFound, nil)
+	})
+}
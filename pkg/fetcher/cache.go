@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/log"
+)
+
+const (
+	defaultMaxStaleness     = time.Hour
+	defaultMaxDeletionRatio = 0.5
+)
+
+// Cache decorates the fetcher with the last-known-good collection. If the fetcher fails,
+// the last-known-good collection is returned until it becomes older than MaxStaleness.
+// If the fetched collection removes too many streams at once, for example it is empty due to a partial outage
+// of the source, it is refused in favor of the last-known-good collection, also until MaxStaleness
+type Cache struct {
+	next    glance.Fetcher
+	options *CacheOptions
+
+	mu        sync.Mutex
+	last      glance.Collection
+	fetchedAt time.Time
+}
+
+type CacheOptions struct {
+	// MaxStaleness how long the last-known-good collection replaces failed or refused fetches, by default 1 hour
+	MaxStaleness time.Duration
+	// MaxDeletionRatio the maximum share of streams of the last-known-good collection that can be removed
+	// in one fetch, from 0 to 1, by default 0.5. Negative value disables the safeguard
+	MaxDeletionRatio float64
+}
+
+func NewCache(next glance.Fetcher, options *CacheOptions) *Cache {
+	if options == nil {
+		options = &CacheOptions{}
+	}
+
+	opts := *options
+	if opts.MaxStaleness <= 0 {
+		opts.MaxStaleness = defaultMaxStaleness
+	}
+
+	if opts.MaxDeletionRatio == 0 {
+		opts.MaxDeletionRatio = defaultMaxDeletionRatio
+	}
+
+	cache := &Cache{next: next, options: &opts}
+	return cache
+}
+
+func (c *Cache) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	collection, err := c.next.FetchStreams(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		if !c.fresh() {
+			return glance.Collection{}, err
+		}
+
+		log.Warning(fmt.Sprintf("[FETCHER] %s, using the last known good streams fetched at %s",
+			err, glance.Datetime(c.fetchedAt)),
+		)
+
+		return c.last, nil
+	}
+
+	if removed, ratio := c.deletion(collection); ratio > c.options.MaxDeletionRatio && c.fresh() {
+		log.Warning(fmt.Sprintf("[FETCHER] refused to remove %d of %d streams at once, using the last known good streams fetched at %s",
+			removed, len(c.last.Streams), glance.Datetime(c.fetchedAt)),
+		)
+
+		return c.last, nil
+	}
+
+	c.last, c.fetchedAt = collection, time.Now()
+
+	return collection, nil
+}
+
+// WatchStreams passes the changes of the decorated fetcher through, if it supports watching,
+// otherwise glance.ErrorWatchIsNotSupported is returned and the streams are only polled
+func (c *Cache) WatchStreams(ctx context.Context) (<-chan glance.StreamChange, error) {
+	watchable, ok := c.next.(glance.WatchableFetcher)
+	if !ok {
+		return nil, glance.ErrorWatchIsNotSupported
+	}
+
+	return watchable.WatchStreams(ctx)
+}
+
+// fresh reports whether the last-known-good collection can be used, the caller must hold the lock
+func (c *Cache) fresh() bool {
+	return !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) <= c.options.MaxStaleness
+}
+
+// deletion returns the number and the share of streams of the last-known-good collection
+// that are missing in the fetched one, the caller must hold the lock
+func (c *Cache) deletion(collection glance.Collection) (removed int, ratio float64) {
+	if c.options.MaxDeletionRatio < 0 || len(c.last.Streams) == 0 {
+		return 0, 0
+	}
+
+	for id := range c.last.Streams {
+		if !collection.Exist(id) {
+			removed++
+		}
+	}
+
+	return removed, float64(removed) / float64(len(c.last.Streams))
+}
@@ -0,0 +1,119 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+type MockFetcher struct {
+	collection glance.Collection
+	err        error
+}
+
+func (f *MockFetcher) FetchStreams(_ context.Context) (glance.Collection, error) {
+	return f.collection, f.err
+}
+
+type MockWatchableFetcher struct {
+	MockFetcher
+	changes chan glance.StreamChange
+}
+
+func (f *MockWatchableFetcher) WatchStreams(_ context.Context) (<-chan glance.StreamChange, error) {
+	return f.changes, nil
+}
+
+func streams(ids ...string) glance.Collection {
+	collection := glance.Collection{Streams: map[string]glance.WorkerItem{}}
+	for _, id := range ids {
+		collection.Streams[id] = glance.WorkerItem{ID: id, URL: "rtmp://localhost/" + id}
+	}
+
+	return collection
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	source := &MockFetcher{collection: streams("1", "2", "3", "4")}
+	cache := NewCache(source, &CacheOptions{MaxStaleness: time.Millisecond * 100, MaxDeletionRatio: 0.5})
+
+	t.Run("it should be fail without last known good streams", func(t *testing.T) {
+		failing := NewCache(&MockFetcher{err: errors.New("source is down")}, nil)
+		if _, err := failing.FetchStreams(ctx); err == nil {
+			t.Fatal("Failed, expect error")
+		}
+	})
+
+	t.Run("it should be return last known good streams on error", func(t *testing.T) {
+		if _, err := cache.FetchStreams(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		source.err = errors.New("source is down")
+		collection, err := cache.FetchStreams(ctx)
+		if err != nil || len(collection.Streams) != 4 {
+			t.Fatalf("Failed, expect cached streams, give %d %v", len(collection.Streams), err)
+		}
+
+		source.err = nil
+	})
+
+	t.Run("it should be refuse mass deletion", func(t *testing.T) {
+		source.collection = streams()
+		collection, err := cache.FetchStreams(ctx)
+		if err != nil || len(collection.Streams) != 4 {
+			t.Fatalf("Failed, expect refused empty result, give %d %v", len(collection.Streams), err)
+		}
+
+		source.collection = streams("1", "2", "3")
+		collection, err = cache.FetchStreams(ctx)
+		if err != nil || len(collection.Streams) != 3 {
+			t.Fatalf("Failed, expect accepted deletion of one stream, give %d %v", len(collection.Streams), err)
+		}
+	})
+
+	t.Run("it should be expire last known good streams", func(t *testing.T) {
+		<-time.After(time.Millisecond * 150)
+
+		source.collection = streams()
+		collection, err := cache.FetchStreams(ctx)
+		if err != nil || len(collection.Streams) != 0 {
+			t.Fatalf("Failed, expect accepted deletion of stale streams, give %d %v", len(collection.Streams), err)
+		}
+
+		source.err = errors.New("source is down")
+		<-time.After(time.Millisecond * 150)
+
+		if _, err := cache.FetchStreams(ctx); err == nil {
+			t.Fatal("Failed, expect error after staleness limit")
+		}
+	})
+}
+
+func TestCacheWatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it should be pass the changes of the watchable fetcher through", func(t *testing.T) {
+		source := &MockWatchableFetcher{changes: make(chan glance.StreamChange, 1)}
+		source.changes <- glance.StreamChange{Type: glance.StreamAdded, Stream: glance.WorkerItem{ID: "1"}}
+
+		changes, err := NewCache(source, nil).WatchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if change := <-changes; change.Type != glance.StreamAdded || change.Stream.ID != "1" {
+			t.Fatalf("Failed, expect change of the source, give %+v", change)
+		}
+	})
+
+	t.Run("it should be refuse to watch the fetcher without watching", func(t *testing.T) {
+		if _, err := NewCache(&MockFetcher{}, nil).WatchStreams(ctx); !errors.Is(err, glance.ErrorWatchIsNotSupported) {
+			t.Fatalf("Failed, expect not supported error, give %v", err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+// DefaultPlaybackTemplate the playback URL of the discovered stream on the host of the media server
+const DefaultPlaybackTemplate = "rtmp://{host}/{app}/{stream}"
+
+// DiscoveryOptions options of the fetchers that discover live streams on the media server
+type DiscoveryOptions struct {
+	// Template of the playback URL, the placeholders {host}, {app} and {stream} are replaced
+	// with the hostname of the media server, the application and the stream name,
+	// for example http://{host}:8080/{app}/{stream}.flv, by default DefaultPlaybackTemplate.
+	// The placeholder {vhost} is replaced with the virtual host of SRS, empty for the default one
+	Template string
+	// Labels are added to the labels of every discovered stream
+	Labels map[string]string
+	// Headers of the request, for example Authorization
+	Headers map[string]string
+	// Timeout of the request, by default 10 seconds
+	Timeout time.Duration
+	// Client by default http.DefaultClient
+	Client *http.Client
+}
+
+// live the stream published on the media server, the vhost is empty for the default virtual host
+type live struct {
+	vhost  string
+	app    string
+	stream string
+}
+
+// discover builds the collection of the live streams, the ID of the stream is app/stream or vhost/app/stream
+func discover(server string, options *DiscoveryOptions, source string, streams []live) (glance.Collection, error) {
+	template := options.Template
+	if template == "" {
+		template = DefaultPlaybackTemplate
+	}
+
+	host := ""
+	if u, err := url.Parse(server); err == nil {
+		host = u.Hostname()
+	}
+
+	seen := make(map[string]bool, len(streams))
+	descriptors := make([]descriptor, 0, len(streams))
+	for _, s := range streams {
+		// the same stream can be listed by several servers of the media server
+		id := s.app + "/" + s.stream
+		if s.vhost != "" {
+			id = s.vhost + "/" + id
+		}
+
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		labels := make(map[string]string, len(options.Labels)+4)
+		for name, value := range options.Labels {
+			labels[name] = value
+		}
+
+		labels["source"] = source
+		labels["app"] = s.app
+		labels["stream"] = s.stream
+		if s.vhost != "" {
+			labels["vhost"] = s.vhost
+		}
+
+		descriptors = append(descriptors, descriptor{
+			ID:     id,
+			URL:    strings.NewReplacer("{host}", host, "{vhost}", s.vhost, "{app}", s.app, "{stream}", s.stream).Replace(template),
+			Labels: labels,
+		})
+	}
+
+	return collect(descriptors)
+}
+
+// errNotModified the content of the endpoint has not changed since the response with the ETag
+var errNotModified = errors.New("not modified")
+
+// get reads the page of the media server API or the remote playlist
+func get(ctx context.Context, client *http.Client, headers map[string]string, timeout time.Duration, endpoint string) ([]byte, error) {
+	content, _, err := getTagged(ctx, client, headers, timeout, endpoint, "")
+	return content, err
+}
+
+// getTagged reads the endpoint like get and returns the ETag of the response. If the ETag of the previous response
+// is passed and the content has not changed, errNotModified is returned
+func getTagged(
+	ctx context.Context, client *http.Client, headers map[string]string, timeout time.Duration, endpoint, etag string,
+) ([]byte, string, error) {
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, etag, errNotModified
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch %s: HTTP code %d", endpoint, res.StatusCode)
+	}
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return content, res.Header.Get("ETag"), nil
+}
@@ -0,0 +1,168 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+const nginxStatXML = `<?xml version="1.0" encoding="utf-8" ?>
+<rtmp>
+  <nginx_version>1.21.6</nginx_version>
+  <server>
+    <application>
+      <name>live</name>
+      <live>
+        <stream>
+          <name>first</name>
+          <bw_in>1024</bw_in>
+          <client><id>1</id><publishing/><active/></client>
+          <publishing/>
+          <active/>
+        </stream>
+        <stream>
+          <name>idle</name>
+          <client><id>2</id></client>
+        </stream>
+        <nclients>2</nclients>
+      </live>
+    </application>
+    <application>
+      <name>hls</name>
+      <live>
+        <stream>
+          <name>second</name>
+          <publishing/>
+        </stream>
+      </live>
+    </application>
+  </server>
+</rtmp>`
+
+func TestNginxRTMP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(nginxStatXML))
+	}))
+	defer server.Close()
+
+	t.Run("it should be discover published streams", func(t *testing.T) {
+		fetcher := NewNginxRTMP(server.URL+"/stat", &DiscoveryOptions{
+			Template: "http://cdn.local/{app}/{stream}.m3u8",
+			Labels:   map[string]string{"origin": "eu"},
+		})
+
+		collection, err := fetcher.FetchStreams(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != 2 || collection.Exist("live/idle") {
+			t.Fatalf("Failed, expect only published streams, give %v", collection.Streams)
+		}
+
+		stream := collection.Streams["live/first"]
+		if stream.URL != "http://cdn.local/live/first.m3u8" {
+			t.Fatalf("Failed, expect playback URL from template, give %s", stream.URL)
+		}
+
+		if stream.Labels["source"] != "nginx-rtmp" || stream.Labels["app"] != "live" || stream.Labels["origin"] != "eu" {
+			t.Fatalf("Failed, give labels %v", stream.Labels)
+		}
+	})
+}
+
+func TestSRS(t *testing.T) {
+	const total = 150
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/vhosts" {
+			_, _ = w.Write([]byte(`{"code": 0, "vhosts": [{"id": "vid-1", "name": "__defaultVhost__"}, {"id": "vid-2", "name": "news"}]}`))
+			return
+		}
+
+		if r.URL.Path != "/api/v1/streams" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+
+		_, _ = w.Write([]byte(`{"code": 0, "server": "vid-0", "streams": [`))
+		for i := start; i < start+count && i < total; i++ {
+			if i > start {
+				_, _ = w.Write([]byte(","))
+			}
+
+			vhost := "vid-1"
+			if i == total-1 {
+				vhost = "vid-2"
+			}
+
+			_, _ = fmt.Fprintf(w, `{"id": "vid-%d", "name": "stream%d", "vhost": "%s", "app": "live",
+				"tcUrl": "rtmp://127.0.0.1:1935/live", "publish": {"active": %t, "cid": "x"}, "video": null}`, i, i, vhost, i != 0)
+		}
+		_, _ = w.Write([]byte(`]}`))
+	}))
+	defer server.Close()
+
+	t.Run("it should be discover streams of all pages", func(t *testing.T) {
+		collection, err := NewSRS(server.URL, nil).FetchStreams(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != total-1 || collection.Exist("live/stream0") {
+			t.Fatalf("Failed, expect %d active streams, give %d", total-1, len(collection.Streams))
+		}
+
+		if stream := collection.Streams["live/stream148"]; stream.URL != "rtmp://127.0.0.1/live/stream148" {
+			t.Fatalf("Failed, expect default playback URL, give %s", stream.URL)
+		}
+
+		if stream, ok := collection.Streams["news/live/stream149"]; !ok || stream.Labels["vhost"] != "news" {
+			t.Fatalf("Failed, expect stream of named vhost, give %+v", stream)
+		}
+	})
+
+	t.Run("it should be stop paging if pages are repeated", func(t *testing.T) {
+		requests := 0
+		repeating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			_, _ = w.Write([]byte(`{"code": 0, "streams": [`))
+			for i := 0; i < srsPageSize; i++ {
+				if i > 0 {
+					_, _ = w.Write([]byte(","))
+				}
+
+				_, _ = fmt.Fprintf(w, `{"name": "stream%d", "app": "live", "publish": {"active": true}}`, i)
+			}
+			_, _ = w.Write([]byte(`]}`))
+		}))
+		defer repeating.Close()
+
+		collection, err := NewSRS(repeating.URL, nil).FetchStreams(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != srsPageSize || requests != 2 {
+			t.Fatalf("Failed, expect %d streams from two requests, give %d from %d", srsPageSize, len(collection.Streams), requests)
+		}
+	})
+
+	t.Run("it should be fail on error code", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"code": 1005}`))
+		}))
+		defer failing.Close()
+
+		if _, err := NewSRS(failing.URL, nil).FetchStreams(context.Background()); err == nil {
+			t.Fatal("Failed, expect error")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/zikwall/glance"
+)
+
+// descriptor the stream in the documents of the file and HTTP fetchers:
+//
+// {"streams": [{"id": "1", "url": "rtmp://...", "priority": 1, "labels": {"tenant": "acme"}, "headers": {}, "options": {}}]}
+type descriptor struct {
+	ID       string            `json:"id" yaml:"id"`
+	URL      string            `json:"url" yaml:"url"`
+	Priority int               `json:"priority" yaml:"priority"`
+	Labels   map[string]string `json:"labels" yaml:"labels"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	Options  map[string]string `json:"options" yaml:"options"`
+}
+
+type document struct {
+	Streams []descriptor `json:"streams" yaml:"streams"`
+}
+
+// collect converts the descriptors into the collection, the streams without ID or URL are invalid
+func collect(descriptors []descriptor) (glance.Collection, error) {
+	collection := glance.Collection{Streams: make(map[string]glance.WorkerItem, len(descriptors))}
+	for i := range descriptors {
+		d := &descriptors[i]
+		if d.ID == "" || d.URL == "" {
+			return collection, fmt.Errorf("stream #%d: id and url are required", i)
+		}
+
+		if _, ok := collection.Streams[d.ID]; ok {
+			return collection, fmt.Errorf("stream %s: duplicated id", d.ID)
+		}
+
+		collection.Streams[d.ID] = glance.WorkerItem{
+			ID:       d.ID,
+			URL:      d.URL,
+			Priority: d.Priority,
+			Labels:   d.Labels,
+			Headers:  d.Headers,
+			Options:  d.Options,
+		}
+	}
+
+	return collection, nil
+}
+
+// decodeJSON accepts both the document and the plain list of streams
+func decodeJSON(content []byte) (glance.Collection, error) {
+	doc := document{}
+
+	var err error
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &doc.Streams)
+	} else {
+		err = json.Unmarshal(content, &doc)
+	}
+
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	return collect(doc.Streams)
+}
+
+// diff returns the changes that turn the previous collection into the next one
+func diff(previous, next glance.Collection) []glance.StreamChange {
+	var changes []glance.StreamChange
+	for id, stream := range next.Streams {
+		old, ok := previous.Streams[id]
+		switch {
+		case !ok:
+			changes = append(changes, glance.StreamChange{Type: glance.StreamAdded, Stream: stream})
+		case old.Changed(stream) || old.Priority != stream.Priority:
+			changes = append(changes, glance.StreamChange{Type: glance.StreamUpdated, Stream: stream})
+		}
+	}
+
+	for id, stream := range previous.Streams {
+		if !next.Exist(id) {
+			changes = append(changes, glance.StreamChange{Type: glance.StreamRemoved, Stream: stream})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,139 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/log"
+)
+
+const defaultWatchInterval = time.Second * 5
+
+// File reads the streams from the YAML or JSON file, the format is chosen by the extension (.yaml, .yml or .json).
+// The file is reloaded when it changes, the fetcher also implements glance.WatchableFetcher,
+// so the process scheduler applies the changes of the file without waiting for the refresh
+type File struct {
+	path    string
+	options *FileOptions
+
+	mu         sync.Mutex
+	modifiedAt time.Time
+	size       int64
+	collection glance.Collection
+}
+
+type FileOptions struct {
+	// WatchInterval how often the file is checked for changes while it is watched, by default 5 seconds
+	WatchInterval time.Duration
+}
+
+func NewFile(path string, options *FileOptions) *File {
+	if options == nil {
+		options = &FileOptions{}
+	}
+
+	file := &File{path: path, options: options}
+	return file
+}
+
+// FetchStreams returns the streams of the file, the file is read again only if it has changed
+func (f *File) FetchStreams(_ context.Context) (glance.Collection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	if f.collection.Streams != nil && stat.ModTime().Equal(f.modifiedAt) && stat.Size() == f.size {
+		return f.collection, nil
+	}
+
+	collection, err := f.read()
+	if err != nil {
+		return glance.Collection{}, fmt.Errorf("failed to read streams from %s: %w", f.path, err)
+	}
+
+	f.collection, f.modifiedAt, f.size = collection, stat.ModTime(), stat.Size()
+
+	return collection, nil
+}
+
+// WatchStreams emits the changes of the file until the context is done
+func (f *File) WatchStreams(ctx context.Context) (<-chan glance.StreamChange, error) {
+	previous, err := f.FetchStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := f.options.WatchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	changes := make(chan glance.StreamChange)
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next, err := f.FetchStreams(ctx)
+			if err != nil {
+				// the file can be temporarily missing or invalid while it is being replaced
+				log.Warning(err)
+				continue
+			}
+
+			for _, change := range diff(previous, next) {
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			previous = next
+		}
+	}()
+
+	return changes, nil
+}
+
+func (f *File) read() (glance.Collection, error) {
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	switch strings.ToLower(filepath.Ext(f.path)) {
+	case ".yaml", ".yml":
+		doc := document{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return glance.Collection{}, err
+		}
+
+		return collect(doc.Streams)
+	case ".json":
+		return decodeJSON(content)
+	default:
+		return glance.Collection{}, fmt.Errorf("unsupported format of the file, expect .yaml, .yml or .json")
+	}
+}
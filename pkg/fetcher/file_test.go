@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+const yamlStreams = `
+streams:
+  - id: "1"
+    url: rtmp://localhost/1
+    priority: 10
+    labels:
+      tenant: acme
+    headers:
+      Authorization: Bearer token
+  - id: "2"
+    url: rtmp://localhost/2
+`
+
+func TestFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+
+	t.Run("it should be read YAML file", func(t *testing.T) {
+		path := filepath.Join(dir, "streams.yaml")
+		if err := ioutil.WriteFile(path, []byte(yamlStreams), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		collection, err := NewFile(path, nil).FetchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream := collection.Streams["1"]
+		if len(collection.Streams) != 2 || stream.Priority != 10 || stream.Labels["tenant"] != "acme" ||
+			stream.Headers["Authorization"] != "Bearer token" {
+			t.Fatalf("Failed, give %+v", collection.Streams)
+		}
+	})
+
+	t.Run("it should be read JSON file and reject invalid streams", func(t *testing.T) {
+		path := filepath.Join(dir, "streams.json")
+		if err := ioutil.WriteFile(path, []byte(`[{"id": "1", "url": "rtmp://localhost/1"}]`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		collection, err := NewFile(path, nil).FetchStreams(ctx)
+		if err != nil || !collection.Exist("1") {
+			t.Fatalf("Failed, expect stream #1, give %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(`{"streams": [{"id": "1"}]}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewFile(path, nil).FetchStreams(ctx); err == nil {
+			t.Fatal("Failed, expect error for stream without URL")
+		}
+	})
+
+	t.Run("it should be watch changes of file", func(t *testing.T) {
+		path := filepath.Join(dir, "watched.json")
+		if err := ioutil.WriteFile(path, []byte(`[{"id": "1", "url": "rtmp://localhost/1"}]`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err := NewFile(path, &FileOptions{WatchInterval: time.Millisecond * 10}).WatchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content := `[{"id": "1", "url": "rtmp://origin/1"}, {"id": "2", "url": "rtmp://localhost/2"}]`
+		if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		received := map[string]glance.StreamChangeType{}
+		for len(received) < 2 {
+			select {
+			case change := <-changes:
+				received[change.Stream.ID] = change.Type
+			case <-time.After(time.Second):
+				t.Fatalf("Failed, expect changes of file, give %v", received)
+			}
+		}
+
+		if received["1"] != glance.StreamUpdated || received["2"] != glance.StreamAdded {
+			t.Fatalf("Failed, give %v", received)
+		}
+	})
+}
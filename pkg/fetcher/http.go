@@ -0,0 +1,73 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+const defaultHTTPTimeout = time.Second * 10
+
+// HTTP pulls the JSON list of streams from the endpoint, in the same format as the File fetcher.
+// The ETag of the response is remembered, and if the list has not changed, the cached collection is returned
+type HTTP struct {
+	url     string
+	options *HTTPOptions
+	// headers the headers of the options and the Accept header of JSON
+	headers map[string]string
+
+	mu         sync.Mutex
+	etag       string
+	collection glance.Collection
+}
+
+type HTTPOptions struct {
+	// Headers of the request, for example Authorization
+	Headers map[string]string
+	// Timeout of the request, by default 10 seconds
+	Timeout time.Duration
+	// Client by default http.DefaultClient
+	Client *http.Client
+}
+
+func NewHTTP(url string, options *HTTPOptions) *HTTP {
+	if options == nil {
+		options = &HTTPOptions{}
+	}
+
+	headers := map[string]string{"Accept": "application/json"}
+	for name, value := range options.Headers {
+		headers[name] = value
+	}
+
+	fetcher := &HTTP{url: url, options: options, headers: headers}
+	return fetcher
+}
+
+func (h *HTTP) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	content, etag, err := getTagged(ctx, h.options.Client, h.headers, h.options.Timeout, h.url, h.etag)
+	if errors.Is(err, errNotModified) {
+		return h.collection, nil
+	}
+
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	collection, err := decodeJSON(content)
+	if err != nil {
+		return glance.Collection{}, fmt.Errorf("failed to decode streams from %s: %w", h.url, err)
+	}
+
+	h.collection, h.etag = collection, etag
+
+	return collection, nil
+}
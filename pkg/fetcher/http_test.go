@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	ctx := context.Background()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"streams": [{"id": "1", "url": "rtmp://localhost/1", "labels": {"tier": "premium"}}]}`))
+	}))
+	defer server.Close()
+
+	t.Run("it should be fetch streams with auth headers", func(t *testing.T) {
+		fetcher := NewHTTP(server.URL, &HTTPOptions{Headers: map[string]string{"Authorization": "Bearer token"}})
+
+		collection, err := fetcher.FetchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if collection.Streams["1"].Labels["tier"] != "premium" {
+			t.Fatalf("Failed, give %+v", collection.Streams)
+		}
+
+		collection, err = fetcher.FetchStreams(ctx)
+		if err != nil || !collection.Exist("1") {
+			t.Fatalf("Failed, expect cached streams on 304, give %v", err)
+		}
+
+		if requests != 2 {
+			t.Fatalf("Failed, expect two requests, give %d", requests)
+		}
+	})
+
+	t.Run("it should be fail without auth headers", func(t *testing.T) {
+		if _, err := NewHTTP(server.URL, nil).FetchStreams(ctx); err == nil {
+			t.Fatal("Failed, expect error for HTTP code 401")
+		}
+	})
+}
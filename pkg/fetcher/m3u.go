@@ -0,0 +1,199 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" // nolint:gosec // its OK, the hash is used only as the stable stream ID
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+// M3U reads the streams from the extended M3U playlist, local or remote (http:// or https://).
+// The tvg-id attribute is the ID of the stream, or the hash of the URL if there is no tvg-id,
+// all attributes of #EXTINF and the title of the channel (name) become the labels of the stream
+type M3U struct {
+	source  string
+	options *M3UOptions
+}
+
+type M3UOptions struct {
+	// IncludeGroups if not empty, only the channels of these groups (group-title) are returned
+	IncludeGroups []string
+	// ExcludeGroups the channels of these groups are skipped
+	ExcludeGroups []string
+	// Headers of the request of the remote playlist
+	Headers map[string]string
+	// Timeout of the request of the remote playlist, by default 10 seconds
+	Timeout time.Duration
+	// Client by default http.DefaultClient
+	Client *http.Client
+}
+
+func NewM3U(source string, options *M3UOptions) *M3U {
+	if options == nil {
+		options = &M3UOptions{}
+	}
+
+	fetcher := &M3U{source: source, options: options}
+	return fetcher
+}
+
+func (m *M3U) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	var content []byte
+	var err error
+
+	if strings.HasPrefix(m.source, "http://") || strings.HasPrefix(m.source, "https://") {
+		content, err = get(ctx, m.options.Client, m.options.Headers, m.options.Timeout, m.source)
+	} else {
+		content, err = ioutil.ReadFile(m.source)
+	}
+
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	seen := map[string]bool{}
+	descriptors := make([]descriptor, 0)
+	for _, channel := range parseM3U(content) {
+		if !m.accepts(channel.Labels["group-title"]) {
+			continue
+		}
+
+		channel.ID = channel.Labels["tvg-id"]
+		if channel.ID == "" {
+			hash := sha1.Sum([]byte(channel.URL)) // nolint:gosec // its OK
+			channel.ID = hex.EncodeToString(hash[:8])
+		}
+
+		// the same channel is often listed in several groups, the first one wins
+		if seen[channel.ID] {
+			continue
+		}
+
+		seen[channel.ID] = true
+		descriptors = append(descriptors, channel)
+	}
+
+	return collect(descriptors)
+}
+
+func (m *M3U) accepts(group string) bool {
+	for _, excluded := range m.options.ExcludeGroups {
+		if strings.EqualFold(excluded, group) {
+			return false
+		}
+	}
+
+	if len(m.options.IncludeGroups) == 0 {
+		return true
+	}
+
+	for _, included := range m.options.IncludeGroups {
+		if strings.EqualFold(included, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseM3U returns the channels of the playlist without IDs, the URL of the channel is the first line after #EXTINF,
+// which is not a directive, #EXTGRP sets the group of the channel if there is no group-title attribute
+func parseM3U(content []byte) []descriptor {
+	var channels []descriptor
+	var current *descriptor
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			labels, name := parseExtinf(strings.TrimPrefix(line, "#EXTINF:"))
+			if name != "" {
+				labels["name"] = name
+			}
+
+			current = &descriptor{Labels: labels}
+		case strings.HasPrefix(line, "#EXTGRP:"):
+			if current != nil && current.Labels["group-title"] == "" {
+				current.Labels["group-title"] = strings.TrimSpace(strings.TrimPrefix(line, "#EXTGRP:"))
+			}
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			if current == nil {
+				// plain M3U without #EXTINF
+				current = &descriptor{Labels: map[string]string{}}
+			}
+
+			current.URL = line
+			channels = append(channels, *current)
+			current = nil
+		}
+	}
+
+	return channels
+}
+
+// parseExtinf parses the duration, the attributes and the title: -1 tvg-id="ch1" group-title="News",Channel 1
+func parseExtinf(value string) (attributes map[string]string, title string) {
+	attributes = map[string]string{}
+
+	// skip the duration
+	i := strings.IndexAny(value, " \t,")
+	if i < 0 {
+		return attributes, ""
+	}
+
+	rest := value[i:]
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" || rest[0] == ',' {
+			break
+		}
+
+		eq := strings.IndexAny(rest, "=, \t")
+		if eq < 0 || rest[eq] != '=' {
+			// the token without value, skip it
+			if eq < 0 {
+				return attributes, ""
+			}
+
+			rest = rest[eq:]
+			continue
+		}
+
+		name, tail := rest[:eq], rest[eq+1:]
+		var val string
+		if strings.HasPrefix(tail, `"`) {
+			end := strings.IndexByte(tail[1:], '"')
+			if end < 0 {
+				val, rest = tail[1:], ""
+			} else {
+				val, rest = tail[1:end+1], tail[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(tail, ", \t")
+			if end < 0 {
+				end = len(tail)
+			}
+
+			val, rest = tail[:end], tail[end:]
+		}
+
+		attributes[strings.ToLower(name)] = val
+	}
+
+	return attributes, strings.TrimSpace(strings.TrimPrefix(rest, ","))
+}
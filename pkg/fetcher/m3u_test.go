@@ -0,0 +1,80 @@
+package fetcher
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const playlist = `#EXTM3U x-tvg-url="http://epg.local/guide.xml"
+#EXTINF:-1 tvg-id="news.one" tvg-name="News One" tvg-logo="http://logo.local/1.png" group-title="News",News One HD
+#EXTVLCOPT:http-user-agent=Glance
+http://iptv.local/news/one.m3u8
+
+#EXTINF:-1 tvg-id="news.one" group-title="Favorites",News One
+http://iptv.local/news/one.m3u8
+#EXTINF:-1 tvg-name="Sport, Live" group-title="Sport",Sport Live
+http://iptv.local/sport/live.m3u8
+#EXTINF:-1 group-title="Adult",Hidden
+http://iptv.local/adult/hidden.m3u8
+#EXTINF:0,Legacy
+#EXTGRP:Archive
+http://iptv.local/archive/legacy.m3u8
+`
+
+func TestM3U(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "playlist.m3u")
+	if err := ioutil.WriteFile(path, []byte(playlist), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should be parse local playlist", func(t *testing.T) {
+		collection, err := NewM3U(path, &M3UOptions{ExcludeGroups: []string{"adult"}}).FetchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != 3 {
+			t.Fatalf("Failed, expect three streams, give %v", collection.Streams)
+		}
+
+		news := collection.Streams["news.one"]
+		if news.URL != "http://iptv.local/news/one.m3u8" || news.Labels["group-title"] != "News" ||
+			news.Labels["name"] != "News One HD" || news.Labels["tvg-logo"] != "http://logo.local/1.png" {
+			t.Fatalf("Failed, give %+v", news)
+		}
+
+		for id, stream := range collection.Streams {
+			switch stream.URL {
+			case "http://iptv.local/sport/live.m3u8":
+				if stream.Labels["tvg-name"] != "Sport, Live" || stream.Labels["name"] != "Sport Live" || len(id) != 16 {
+					t.Fatalf("Failed, expect hash ID and quoted attribute, give %s %v", id, stream.Labels)
+				}
+			case "http://iptv.local/archive/legacy.m3u8":
+				if stream.Labels["group-title"] != "Archive" || stream.Labels["name"] != "Legacy" {
+					t.Fatalf("Failed, expect group from #EXTGRP, give %v", stream.Labels)
+				}
+			}
+		}
+	})
+
+	t.Run("it should be include only selected groups of remote playlist", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(playlist))
+		}))
+		defer server.Close()
+
+		collection, err := NewM3U(server.URL+"/playlist.m3u", &M3UOptions{IncludeGroups: []string{"sport", "favorites"}}).FetchStreams(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != 2 || collection.Streams["news.one"].Labels["group-title"] != "Favorites" {
+			t.Fatalf("Failed, give %v", collection.Streams)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/zikwall/glance"
+)
+
+// NginxRTMP discovers the streams published on the nginx-rtmp module by parsing its statistics page (stat.xml),
+// only the streams with active publisher are returned
+type NginxRTMP struct {
+	stat    string
+	options *DiscoveryOptions
+}
+
+type nginxStat struct {
+	Servers []struct {
+		Applications []struct {
+			Name    string `xml:"name"`
+			Streams []struct {
+				Name       string    `xml:"name"`
+				Publishing *struct{} `xml:"publishing"`
+			} `xml:"live>stream"`
+		} `xml:"application"`
+	} `xml:"server"`
+}
+
+// NewNginxRTMP creates the fetcher for the statistics page, for example http://localhost:8080/stat
+func NewNginxRTMP(stat string, options *DiscoveryOptions) *NginxRTMP {
+	if options == nil {
+		options = &DiscoveryOptions{}
+	}
+
+	fetcher := &NginxRTMP{stat: stat, options: options}
+	return fetcher
+}
+
+func (n *NginxRTMP) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	content, err := get(ctx, n.options.Client, n.options.Headers, n.options.Timeout, n.stat)
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	stat := nginxStat{}
+	if err := xml.Unmarshal(content, &stat); err != nil {
+		return glance.Collection{}, fmt.Errorf("failed to decode nginx-rtmp statistics: %w", err)
+	}
+
+	var streams []live
+	for _, server := range stat.Servers {
+		for _, application := range server.Applications {
+			for _, stream := range application.Streams {
+				if stream.Publishing != nil {
+					streams = append(streams, live{app: application.Name, stream: stream.Name})
+				}
+			}
+		}
+	}
+
+	return discover(n.stat, n.options, "nginx-rtmp", streams)
+}
@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/zikwall/glance"
+)
+
+// DefaultSQLQuery selects the streams from the table with the columns of the descriptor
+const DefaultSQLQuery = "SELECT id, url FROM streams"
+
+// SQL reads the streams with the configurable query. The columns are matched by name:
+// id and url are required, priority, labels, headers and options are optional,
+// the last three contain JSON objects, for example {"tenant": "acme"}
+type SQL struct {
+	db    *sql.DB
+	query string
+	args  []interface{}
+}
+
+// NewSQL creates the fetcher with the query and its arguments, the DefaultSQLQuery is used if the query is empty
+func NewSQL(db *sql.DB, query string, args ...interface{}) *SQL {
+	if query == "" {
+		query = DefaultSQLQuery
+	}
+
+	fetcher := &SQL{db: db, query: query, args: args}
+	return fetcher
+}
+
+// nolint:gocyclo // its OK, simple mapping of columns
+func (s *SQL) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	rows, err := s.db.QueryContext(ctx, s.query, s.args...)
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return glance.Collection{}, err
+	}
+
+	var descriptors []descriptor
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return glance.Collection{}, err
+		}
+
+		d := descriptor{}
+		for i, column := range columns {
+			value := values[i].String
+			if !values[i].Valid || value == "" {
+				continue
+			}
+
+			switch column {
+			case "id":
+				d.ID = value
+			case "url":
+				d.URL = value
+			case "priority":
+				if d.Priority, err = strconv.Atoi(value); err != nil {
+					return glance.Collection{}, fmt.Errorf("stream %s: invalid priority: %w", d.ID, err)
+				}
+			case "labels":
+				err = json.Unmarshal([]byte(value), &d.Labels)
+			case "headers":
+				err = json.Unmarshal([]byte(value), &d.Headers)
+			case "options":
+				err = json.Unmarshal([]byte(value), &d.Options)
+			}
+
+			if err != nil {
+				return glance.Collection{}, fmt.Errorf("stream %s: invalid column %s: %w", d.ID, column, err)
+			}
+		}
+
+		descriptors = append(descriptors, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return glance.Collection{}, err
+	}
+
+	return collect(descriptors)
+}
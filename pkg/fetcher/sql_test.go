@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"testing"
+)
+
+// MockDriver returns the fixed rows for any query
+type MockDriver struct{}
+
+type MockConn struct{}
+
+type MockStmt struct{}
+
+type MockRows struct {
+	position int
+}
+
+var mockColumns = []string{"id", "url", "priority", "labels"}
+
+var mockRows = [][]driver.Value{
+	{"1", "rtmp://localhost/1", int64(10), `{"tenant": "acme"}`},
+	{"2", "rtmp://localhost/2", nil, nil},
+}
+
+func (MockDriver) Open(_ string) (driver.Conn, error) {
+	return MockConn{}, nil
+}
+
+func (MockConn) Prepare(_ string) (driver.Stmt, error) {
+	return MockStmt{}, nil
+}
+
+func (MockConn) Close() error {
+	return nil
+}
+
+func (MockConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (MockStmt) Close() error {
+	return nil
+}
+
+func (MockStmt) NumInput() int {
+	return -1
+}
+
+func (MockStmt) Exec(_ []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (MockStmt) Query(_ []driver.Value) (driver.Rows, error) {
+	return &MockRows{}, nil
+}
+
+func (*MockRows) Columns() []string {
+	return mockColumns
+}
+
+func (*MockRows) Close() error {
+	return nil
+}
+
+func (r *MockRows) Next(dest []driver.Value) error {
+	if r.position >= len(mockRows) {
+		return io.EOF
+	}
+
+	copy(dest, mockRows[r.position])
+	r.position++
+	return nil
+}
+
+var registerMockDriver sync.Once
+
+func TestSQL(t *testing.T) {
+	registerMockDriver.Do(func() {
+		sql.Register("glance-mock", MockDriver{})
+	})
+
+	db, err := sql.Open("glance-mock", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = db.Close()
+	}()
+
+	t.Run("it should be map columns by name", func(t *testing.T) {
+		collection, err := NewSQL(db, "").FetchStreams(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Streams) != 2 {
+			t.Fatalf("Failed, expect two streams, give %d", len(collection.Streams))
+		}
+
+		stream := collection.Streams["1"]
+		if stream.URL != "rtmp://localhost/1" || stream.Priority != 10 || stream.Labels["tenant"] != "acme" {
+			t.Fatalf("Failed, give %+v", stream)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zikwall/glance"
+)
+
+// srsPageSize the SRS API returns only 10 streams by default, so the list is requested page by page
+const srsPageSize = 100
+
+// srsMaxPages limits the paging, if the SRS build keeps returning full pages
+const srsMaxPages = 100
+
+// srsDefaultVhost the name of the default virtual host, it is not included in the ID of the stream
+const srsDefaultVhost = "__defaultVhost__"
+
+// SRS discovers the streams published on the SRS media server through its HTTP API (/api/v1/streams),
+// new publishes are picked up by the next fetch of the scheduler
+type SRS struct {
+	api     string
+	options *DiscoveryOptions
+}
+
+type srsResponse struct {
+	Code    int         `json:"code"`
+	Streams []srsStream `json:"streams"`
+}
+
+type srsStream struct {
+	Name string `json:"name"`
+	App  string `json:"app"`
+	// Vhost the ID of the virtual host, older builds return its name
+	Vhost   string `json:"vhost"`
+	Publish struct {
+		Active bool `json:"active"`
+	} `json:"publish"`
+}
+
+type srsVhostsResponse struct {
+	Code   int `json:"code"`
+	Vhosts []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"vhosts"`
+}
+
+// NewSRS creates the fetcher for the SRS HTTP API, for example http://localhost:1985
+func NewSRS(api string, options *DiscoveryOptions) *SRS {
+	if options == nil {
+		options = &DiscoveryOptions{}
+	}
+
+	fetcher := &SRS{api: strings.TrimSuffix(api, "/"), options: options}
+	return fetcher
+}
+
+func (s *SRS) FetchStreams(ctx context.Context) (glance.Collection, error) {
+	var streams []live
+	var vhosts map[string]string
+	seen := map[string]bool{}
+
+	for page := 0; page < srsMaxPages; page++ {
+		start := page * srsPageSize
+		query := url.Values{}
+		query.Set("start", strconv.Itoa(start))
+		query.Set("count", strconv.Itoa(srsPageSize))
+
+		content, err := get(ctx, s.options.Client, s.options.Headers, s.options.Timeout, s.api+"/api/v1/streams?"+query.Encode())
+		if err != nil {
+			return glance.Collection{}, err
+		}
+
+		response := srsResponse{}
+		if err := json.Unmarshal(content, &response); err != nil {
+			return glance.Collection{}, fmt.Errorf("failed to decode SRS streams: %w", err)
+		}
+
+		if response.Code != 0 {
+			return glance.Collection{}, fmt.Errorf("failed to discover SRS streams: code %d", response.Code)
+		}
+
+		added := 0
+		for _, stream := range response.Streams {
+			if stream.Vhost != "" && vhosts == nil {
+				if vhosts, err = s.vhosts(ctx); err != nil {
+					return glance.Collection{}, err
+				}
+			}
+
+			vhost := stream.Vhost
+			if name, ok := vhosts[vhost]; ok {
+				vhost = name
+			}
+
+			if vhost == srsDefaultVhost {
+				vhost = ""
+			}
+
+			// the SRS build that ignores start and count returns the same streams on every page
+			id := vhost + "/" + stream.App + "/" + stream.Name
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+			added++
+
+			if stream.Publish.Active {
+				streams = append(streams, live{vhost: vhost, app: stream.App, stream: stream.Name})
+			}
+		}
+
+		if len(response.Streams) < srsPageSize || added == 0 {
+			break
+		}
+	}
+
+	return discover(s.api, s.options, "srs", streams)
+}
+
+// vhosts returns the names of the virtual hosts by their IDs
+func (s *SRS) vhosts(ctx context.Context) (map[string]string, error) {
+	content, err := get(ctx, s.options.Client, s.options.Headers, s.options.Timeout, s.api+"/api/v1/vhosts")
+	if err != nil {
+		return nil, err
+	}
+
+	response := srsVhostsResponse{}
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode SRS vhosts: %w", err)
+	}
+
+	if response.Code != 0 {
+		return nil, fmt.Errorf("failed to discover SRS vhosts: code %d", response.Code)
+	}
+
+	vhosts := make(map[string]string, len(response.Vhosts))
+	for _, vhost := range response.Vhosts {
+		vhosts[vhost.ID] = vhost.Name
+	}
+
+	return vhosts, nil
+}
@@ -0,0 +1,150 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const DefaultTerminateTimeout = time.Second * 3
+
+// OptionTerminateTimeout the per-stream option overriding the terminate timeout of the worker, for example "10s"
+const OptionTerminateTimeout = "terminate_timeout"
+
+// TerminateTimeout returns the OptionTerminateTimeout of the stream options, the fallback timeout of the worker
+// or DefaultTerminateTimeout
+func TerminateTimeout(options map[string]string, fallback time.Duration) time.Duration {
+	if value, ok := options[OptionTerminateTimeout]; ok {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+
+	if fallback > 0 {
+		return fallback
+	}
+
+	return DefaultTerminateTimeout
+}
+
+var errGroupNotSupported = errors.New("process groups are not supported on this platform")
+
+// Command the started child process, which is waited for in the background and can be stopped gracefully
+type Command struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+// Start starts the command in its own process group and waits for its completion in the background,
+// so that the process is always reaped, regardless of how the caller completes.
+// The child is marked with the OwnerEnv variable, which is used by ReapOrphans
+func Start(cmd *exec.Cmd) (*Command, error) {
+	setProcessGroup(cmd)
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", OwnerEnv, os.Getpid()))
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Command{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		c.err = cmd.Wait()
+		close(c.exited)
+	}()
+
+	return c, nil
+}
+
+// Exited is closed when the process has completed
+func (c *Command) Exited() <-chan struct{} {
+	return c.exited
+}
+
+// Err the result of waiting for the process, available after Exited is closed
+func (c *Command) Err() error {
+	<-c.exited
+	return c.err
+}
+
+func (c *Command) Pid() int {
+	return c.cmd.Process.Pid
+}
+
+// Kill immediately kills the process group with SIGKILL
+func (c *Command) Kill() error {
+	return c.signal(syscall.SIGKILL)
+}
+
+// Terminate asks the process group to complete with SIGTERM, and kills it with SIGKILL if it has not completed
+// within the timeout. The method returns after the process has completed
+func (c *Command) Terminate(timeout time.Duration) error {
+	select {
+	case <-c.exited:
+		return nil
+	default:
+	}
+
+	if err := c.signal(syscall.SIGTERM); err != nil {
+		return c.killAndWait()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.exited:
+		return nil
+	case <-timer.C:
+		return c.killAndWait()
+	}
+}
+
+func (c *Command) killAndWait() error {
+	if err := c.Kill(); err != nil {
+		return err
+	}
+
+	<-c.exited
+	return nil
+}
+
+// signal sends the signal to the process group of the child, or to the child itself if groups are not supported.
+// After the child has been reaped its PID can be reused, so the signal is no longer sent
+func (c *Command) signal(signal syscall.Signal) error {
+	select {
+	case <-c.exited:
+		return nil
+	default:
+	}
+
+	err := signalGroup(c.Pid(), signal)
+	if errors.Is(err, errGroupNotSupported) {
+		if signal == syscall.SIGKILL {
+			return ignoreFinished(c.cmd.Process.Kill())
+		}
+
+		return ignoreFinished(c.cmd.Process.Signal(signal))
+	}
+
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+
+	return err
+}
+
+func ignoreFinished(err error) error {
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	t.Run("it should be terminate process with SIGTERM", func(t *testing.T) {
+		command, err := Start(exec.Command("sleep", "10"))
+		if err != nil {
+			t.Skip(err)
+		}
+
+		startedAt := time.Now()
+		if err := command.Terminate(time.Second * 5); err != nil {
+			t.Fatal(err)
+		}
+
+		if time.Since(startedAt) >= time.Second*5 {
+			t.Fatal("Failed, expect process completed before the timeout")
+		}
+
+		select {
+		case <-command.Exited():
+		default:
+			t.Fatal("Failed, expect exited process")
+		}
+	})
+
+	t.Run("it should be kill process ignoring SIGTERM", func(t *testing.T) {
+		command, err := Start(exec.Command("sh", "-c", "trap '' TERM; sleep 10"))
+		if err != nil {
+			t.Skip(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		if err := command.Terminate(time.Millisecond * 100); err != nil {
+			t.Fatal(err)
+		}
+
+		if command.Err() == nil {
+			t.Fatal("Failed, expect killed process error")
+		}
+	})
+	t.Run("it should be kill the whole process group", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "pid")
+		command, err := Start(exec.Command("sh", "-c", fmt.Sprintf("sleep 10 & echo $! > %s; wait", pidFile)))
+		if err != nil {
+			t.Skip(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		raw, err := ioutil.ReadFile(pidFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := command.Kill(); err != nil {
+			t.Fatal(err)
+		}
+
+		<-command.Exited()
+		<-time.After(time.Millisecond * 100)
+
+		stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/stat", strings.TrimSpace(string(raw))))
+		if err == nil && !strings.Contains(string(stat), ") Z") {
+			t.Fatal("Failed, expect killed grandchild process")
+		}
+	})
+}
+
+func TestTerminateTimeout(t *testing.T) {
+	t.Run("it should be prefer stream option over fallback", func(t *testing.T) {
+		if timeout := TerminateTimeout(map[string]string{OptionTerminateTimeout: "10s"}, time.Second); timeout != time.Second*10 {
+			t.Fatalf("Failed, expect 10s, give %s", timeout)
+		}
+
+		if timeout := TerminateTimeout(map[string]string{OptionTerminateTimeout: "invalid"}, time.Second); timeout != time.Second {
+			t.Fatalf("Failed, expect fallback 1s, give %s", timeout)
+		}
+
+		if timeout := TerminateTimeout(nil, 0); timeout != DefaultTerminateTimeout {
+			t.Fatalf("Failed, expect default timeout, give %s", timeout)
+		}
+	})
+}
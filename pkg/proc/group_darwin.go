@@ -0,0 +1,22 @@
+//go:build darwin
+// +build darwin
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the child in its own process group, so that the whole group can be killed
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func signalGroup(pid int, signal syscall.Signal) error {
+	return syscall.Kill(-pid, signal)
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the child in its own process group, so that the whole group can be killed,
+// and asks the kernel to kill the child if the thread that started it exits, including a crash of the Go process
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGKILL
+}
+
+func signalGroup(pid int, signal syscall.Signal) error {
+	return syscall.Kill(-pid, signal)
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup process groups are not supported, only the direct child is stopped
+func setProcessGroup(_ *exec.Cmd) {}
+
+func signalGroup(_ int, _ syscall.Signal) error {
+	return errGroupNotSupported
+}
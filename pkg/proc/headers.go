@@ -0,0 +1,32 @@
+package proc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HeadersArgs returns the -headers argument of ffmpeg/ffprobe with the static headers of the worker,
+// each in the form "Name: value", and the per-stream headers. ffmpeg uses only the last -headers argument,
+// so all headers are joined into one value. Returns nil if there are no headers
+func HeadersArgs(static []string, headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(static)+len(names))
+	for _, header := range static {
+		lines = append(lines, strings.TrimRight(header, "\r\n"))
+	}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %s", name, headers[name]))
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []string{"-headers", strings.Join(lines, "\r\n") + "\r\n"}
+}
@@ -0,0 +1,22 @@
+package proc
+
+import "testing"
+
+func TestHeadersArgs(t *testing.T) {
+	t.Run("it should be join headers into one argument", func(t *testing.T) {
+		args := HeadersArgs([]string{"User-Agent: glance\r\n"}, map[string]string{"X-Token": "2", "Authorization": "1"})
+		if len(args) != 2 || args[0] != "-headers" {
+			t.Fatalf("Failed, give %q", args)
+		}
+
+		if expected := "User-Agent: glance\r\nAuthorization: 1\r\nX-Token: 2\r\n"; args[1] != expected {
+			t.Fatalf("Failed, expect %q give %q", expected, args[1])
+		}
+	})
+
+	t.Run("it should be skip empty headers", func(t *testing.T) {
+		if args := HeadersArgs(nil, nil); args != nil {
+			t.Fatalf("Failed, give %q", args)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package proc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// OwnerEnv the environment variable with the PID of the process that started the child with Start
+const OwnerEnv = "GLANCE_OWNER_PID"
+
+// TempFilePattern matches the temporary files of the workers, for example ./tmp/1_go_tmp_stream_err_123.log
+const TempFilePattern = "*_go_tmp_*"
+
+var ErrReapNotSupported = errors.New("reaping of orphaned processes is not supported on this platform")
+
+// RemoveStaleTempFiles removes the temporary files of the workers left in the directory by earlier runs,
+// it must be called at startup before the workers are started, because it removes the files of running tasks too
+func RemoveStaleTempFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, TempFilePattern))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0, len(files))
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+
+		removed = append(removed, file)
+	}
+
+	return removed, nil
+}
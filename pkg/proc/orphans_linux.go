@@ -0,0 +1,95 @@
+//go:build linux
+// +build linux
+
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ReapOrphans kills the children started with Start by earlier runs that have outlived their owner,
+// for example after the owner was killed with SIGKILL. A child is considered orphaned,
+// if it is no longer the child of the process recorded in OwnerEnv. Returns the PIDs of the killed processes
+func ReapOrphans() ([]int, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var reaped []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		orphan, group := isOrphan(pid)
+		if !orphan {
+			continue
+		}
+
+		target := pid
+		if group {
+			target = -pid
+		}
+
+		if err := syscall.Kill(target, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return reaped, fmt.Errorf("failed to kill orphaned process PID %d: %w", pid, err)
+		}
+
+		reaped = append(reaped, pid)
+	}
+
+	return reaped, nil
+}
+
+// isOrphan reports whether the process is an orphaned child and whether it is the leader of its process group.
+// Descendants of the children inherit OwnerEnv, so the process is not orphaned while its parent is the owner
+// or another child of the same owner. Processes of other users and completed processes are skipped
+func isOrphan(pid int) (orphan, leader bool) {
+	owner := ownerOf(pid)
+	if owner <= 0 || pid == os.Getpid() {
+		return false, false
+	}
+
+	fields, err := statFields(pid, pgrpPos)
+	if err != nil {
+		return false, false
+	}
+
+	ppid, _ := strconv.Atoi(fields[ppidPos])
+	pgrp, _ := strconv.Atoi(fields[pgrpPos])
+
+	if ppid == owner || ownerOf(ppid) == owner {
+		return false, false
+	}
+
+	return true, pgrp == pid
+}
+
+// ownerOf returns the PID from OwnerEnv of the process or -1
+func ownerOf(pid int) int {
+	environ, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return -1
+	}
+
+	prefix := []byte(OwnerEnv + "=")
+	for _, variable := range bytes.Split(environ, []byte{0}) {
+		if bytes.HasPrefix(variable, prefix) {
+			owner, err := strconv.Atoi(string(variable[len(prefix):]))
+			if err != nil {
+				return -1
+			}
+
+			return owner
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package proc
+
+// ReapOrphans is supported only on Linux
+func ReapOrphans() ([]int, error) {
+	return nil, ErrReapNotSupported
+}
@@ -0,0 +1,82 @@
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1_go_tmp_stream_err_1.log", "2_go_tmp_capture_2.log", "keep.log"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := RemoveStaleTempFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 2 {
+		t.Fatalf("Failed, expect two removed files, give %v", removed)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "keep.log")); err != nil {
+		t.Fatal("Failed, expect unrelated file is kept")
+	}
+}
+
+func TestReapOrphans(t *testing.T) {
+	// the PID of the completed process plays the role of the crashed owner
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Skip(err)
+	}
+
+	orphan := exec.Command("sleep", "10")
+	orphan.Env = append(os.Environ(), OwnerEnv+"="+strconv.Itoa(dead.Process.Pid))
+	if err := orphan.Start(); err != nil {
+		t.Skip(err)
+	}
+
+	defer func() {
+		_ = orphan.Process.Kill()
+		_ = orphan.Wait()
+	}()
+
+	child, err := Start(exec.Command("sleep", "10"))
+	if err != nil {
+		t.Skip(err)
+	}
+
+	defer func() {
+		_ = child.Kill()
+	}()
+
+	reaped, err := ReapOrphans()
+	if err == ErrReapNotSupported {
+		t.Skip(err)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, pid := range reaped {
+		if pid == child.Pid() {
+			t.Fatal("Failed, expect the child of the running owner is not reaped")
+		}
+
+		found = found || pid == orphan.Process.Pid
+	}
+
+	if !found {
+		t.Fatalf("Failed, expect reaped orphan PID %d, give %v", orphan.Process.Pid, reaped)
+	}
+}
@@ -0,0 +1,25 @@
+package proc
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrUsageNotSupported = errors.New("resource accounting is not supported on this platform")
+
+// Usage the resources consumed by the process since its start
+type Usage struct {
+	CPUTime    time.Duration
+	RSS        uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		CPUTime:    u.CPUTime + other.CPUTime,
+		RSS:        u.RSS + other.RSS,
+		ReadBytes:  u.ReadBytes + other.ReadBytes,
+		WriteBytes: u.WriteBytes + other.WriteBytes,
+	}
+}
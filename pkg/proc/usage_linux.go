@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// USER_HZ, on all supported architectures the kernel reports CPU time in hundredths of a second
+const clockTicks = 100
+
+const (
+	// positions of the fields after the command name in /proc/<pid>/stat
+	ppidPos  = 1
+	pgrpPos  = 2
+	utimePos = 11
+	stimePos = 12
+)
+
+// Sample reads the resource usage of the process from /proc/<pid>,
+// I/O counters are left empty if they are not available to the current user
+func Sample(pid int) (Usage, error) {
+	usage := Usage{}
+
+	fields, err := statFields(pid, stimePos)
+	if err != nil {
+		return usage, err
+	}
+
+	ticks := parseUint(fields[utimePos]) + parseUint(fields[stimePos])
+	usage.CPUTime = time.Duration(ticks) * time.Second / clockTicks
+
+	if err := scanKeyValues(fmt.Sprintf("/proc/%d/status", pid), func(key, value string) {
+		if key == "VmRSS" {
+			usage.RSS = parseUint(strings.TrimSuffix(value, " kB")) * 1024
+		}
+	}); err != nil {
+		return usage, err
+	}
+
+	_ = scanKeyValues(fmt.Sprintf("/proc/%d/io", pid), func(key, value string) {
+		switch key {
+		case "read_bytes":
+			usage.ReadBytes = parseUint(value)
+		case "write_bytes":
+			usage.WriteBytes = parseUint(value)
+		}
+	})
+
+	return usage, nil
+}
+
+// statFields returns the fields of /proc/<pid>/stat after the command name,
+// the command name can contain spaces, so the fields are counted after its closing bracket
+func statFields(pid, minPos int) ([]string, error) {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) <= minPos {
+		return nil, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+
+	return fields, nil
+}
+
+func scanKeyValues(path string, fn func(key, value string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) == 2 {
+			fn(parts[0], strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseUint(s string) uint64 {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package proc
+
+// Sample is supported only on Linux
+func Sample(_ int) (Usage, error) {
+	return Usage{}, ErrUsageNotSupported
+}
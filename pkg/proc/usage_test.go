@@ -0,0 +1,32 @@
+package proc
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	t.Run("it should be sample usage of the current process", func(t *testing.T) {
+		usage, err := Sample(os.Getpid())
+		if errors.Is(err, ErrUsageNotSupported) {
+			t.Skip(err)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if usage.RSS == 0 {
+			t.Fatalf("Failed, expect resident memory, give %+v", usage)
+		}
+	})
+
+	t.Run("it should be sum usages", func(t *testing.T) {
+		usage := Usage{CPUTime: time.Second, RSS: 1}.Add(Usage{CPUTime: time.Second, RSS: 2, ReadBytes: 3})
+		if usage.CPUTime != time.Second*2 || usage.RSS != 3 || usage.ReadBytes != 3 {
+			t.Fatalf("Failed, give %+v", usage)
+		}
+	})
+}
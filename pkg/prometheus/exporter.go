@@ -0,0 +1,328 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/scheduler/httpstat"
+)
+
+const namespace = "glance"
+
+// Exporter exposes the workstation and the last per-stream metrics in the Prometheus text format.
+// Per-stream values are collected by wrapping the storage of the metric worker and the writer of httpstat:
+//
+// exporter := prometheus.New(workstation, &prometheus.Options{StreamTTL: time.Minute * 5})
+// worker := metric.New("metric", exporter.Storage(clickhouseStorage), &metric.Options{})
+// scheduler := httpstat.NewScheduler(fetcher, exporter.StatusWriter(clickhouseWriter), &httpstat.Options{})
+// mux.Handle("/metrics", exporter)
+type Exporter struct {
+	workstation *glance.Workstation
+	options     *Options
+	mu          sync.RWMutex
+	batches     map[string]sample
+	statuses    map[string]sample
+}
+
+type Options struct {
+	// StreamTTL per-stream values that have not been updated for longer than this duration are not exported,
+	// so the gauges of the died or unreachable streams disappear instead of showing the last healthy values.
+	// By default DefaultStreamTTL, a negative value means they are exported forever
+	StreamTTL time.Duration
+}
+
+// DefaultStreamTTL by default the per-stream values are exported for 5 minutes after the last update
+const DefaultStreamTTL = time.Minute * 5
+
+type sample struct {
+	batch     glance.Batch
+	code      int
+	updatedAt time.Time
+}
+
+func New(workstation *glance.Workstation, options *Options) *Exporter {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if options.StreamTTL == 0 {
+		options.StreamTTL = DefaultStreamTTL
+	}
+
+	exporter := &Exporter{
+		workstation: workstation,
+		options:     options,
+		batches:     map[string]sample{},
+		statuses:    map[string]sample{},
+	}
+	return exporter
+}
+
+// Storage wraps the storage of the metric worker, remembering the last batch of each stream,
+// the next storage can be nil
+func (e *Exporter) Storage(next glance.Storage) glance.Storage {
+	return &storage{exporter: e, next: next}
+}
+
+// StatusWriter wraps the writer of the httpstat scheduler, remembering the last HTTP code of each stream,
+// the next writer can be nil
+func (e *Exporter) StatusWriter(next httpstat.StatusWriter) httpstat.StatusWriter {
+	return &statusWriter{exporter: e, next: next}
+}
+
+type storage struct {
+	exporter *Exporter
+	next     glance.Storage
+}
+
+func (s *storage) ProcessFrameBatch(batch *glance.Batch) error {
+	s.exporter.mu.Lock()
+	s.exporter.batches[batch.StreamID] = sample{batch: *batch, updatedAt: time.Now()}
+	s.exporter.mu.Unlock()
+
+	if s.next == nil {
+		return nil
+	}
+
+	return s.next.ProcessFrameBatch(batch)
+}
+
+type statusWriter struct {
+	exporter *Exporter
+	next     httpstat.StatusWriter
+}
+
+func (s *statusWriter) Write(bucket httpstat.Bucket) error {
+	s.exporter.mu.Lock()
+	s.exporter.statuses[bucket.StreamID] = sample{code: bucket.Code, updatedAt: time.Now()}
+	s.exporter.mu.Unlock()
+
+	if s.next == nil {
+		return nil
+	}
+
+	return s.next.Write(bucket)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	buffer := bufio.NewWriter(w)
+	e.writeRuntime(buffer)
+	e.writeWorkspaces(buffer)
+	e.writeStreams(buffer)
+
+	if err := buffer.Flush(); err != nil {
+		log.Warning(err)
+	}
+}
+
+func (e *Exporter) writeRuntime(w *bufio.Writer) {
+	runtime := e.workstation.RuntimeInformation()
+
+	family(w, "uptime_seconds", "gauge", "Uptime of the workstation.")
+	point(w, "uptime_seconds", nil, runtime.Uptime)
+	family(w, "memory_alloc_kilobytes", "gauge", "Allocated heap memory.")
+	point(w, "memory_alloc_kilobytes", nil, float64(runtime.MemoryAlloc))
+	family(w, "gc_total", "counter", "Number of completed GC cycles.")
+	point(w, "gc_total", nil, float64(runtime.NumGC))
+	family(w, "goroutines", "gauge", "Number of goroutines.")
+	point(w, "goroutines", nil, float64(runtime.Gorutines))
+}
+
+func (e *Exporter) writeWorkspaces(w *bufio.Writer) {
+	workspaces := e.workstation.WorkstationInformation().Workspaces
+
+	names := make([]string, 0, len(workspaces))
+	for name := range workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	family(w, "workspace_tasks", "gauge", "Number of tasks of the workspace by state.")
+	for _, name := range names {
+		info := workspaces[name]
+		point(w, "workspace_tasks", []string{"workspace", name, "state", "active"}, float64(info.TotalProcesses))
+		point(w, "workspace_tasks", []string{"workspace", name, "state", "queued"}, float64(info.TotalQueued))
+		point(w, "workspace_tasks", []string{"workspace", name, "state", "failed"}, float64(info.TotalFailed))
+	}
+
+	family(w, "workspace_restarts_total", "counter", "Number of task restarts in the workspace.")
+	for _, name := range names {
+		point(w, "workspace_restarts_total", []string{"workspace", name}, float64(workspaces[name].TotalRestarts))
+	}
+
+	family(w, "task_restarts", "gauge", "Number of restarts of the task since it was started.")
+	for _, name := range names {
+		for i := range workspaces[name].Processes {
+			process := &workspaces[name].Processes[i]
+			point(w, "task_restarts", []string{"workspace", name, "stream_id", process.ID}, float64(process.Restarts))
+		}
+	}
+
+	e.writeResources(w, names, workspaces)
+}
+
+func (e *Exporter) writeResources(w *bufio.Writer, names []string, workspaces map[string]glance.WorkspaceInfo) {
+	resourceMetrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(usage *glance.ResourceUsage) float64
+	}{
+		{"task_cpu_cores", "gauge", "CPU cores used by the child processes of the task.", func(u *glance.ResourceUsage) float64 {
+			return u.CPU
+		}},
+		{"task_cpu_seconds_total", "counter", "CPU time of the child processes of the task.", func(u *glance.ResourceUsage) float64 {
+			return u.CPUTime
+		}},
+		{"task_rss_bytes", "gauge", "Resident memory of the child processes of the task.", func(u *glance.ResourceUsage) float64 {
+			return float64(u.RSS)
+		}},
+		{"task_read_bytes_total", "counter", "Bytes read by the child processes of the task.", func(u *glance.ResourceUsage) float64 {
+			return float64(u.ReadBytes)
+		}},
+		{"task_write_bytes_total", "counter", "Bytes written by the child processes of the task.", func(u *glance.ResourceUsage) float64 {
+			return float64(u.WriteBytes)
+		}},
+	}
+
+	for _, metric := range resourceMetrics {
+		family(w, metric.name, metric.kind, metric.help)
+		for _, name := range names {
+			for i := range workspaces[name].Processes {
+				process := &workspaces[name].Processes[i]
+				if process.Resources == nil {
+					continue
+				}
+
+				point(w, metric.name, []string{"workspace", name, "stream_id", process.ID}, metric.value(process.Resources))
+			}
+		}
+	}
+}
+
+func (e *Exporter) writeStreams(w *bufio.Writer) {
+	active := e.activeStreams()
+
+	e.mu.Lock()
+	e.expire(e.batches)
+	e.expire(e.statuses)
+	// the batches of the tasks that have left the pool are not updated anymore
+	for id := range e.batches {
+		if !active[id] {
+			delete(e.batches, id)
+		}
+	}
+	batches := sortedSamples(e.batches)
+	statuses := sortedSamples(e.statuses)
+	e.mu.Unlock()
+
+	streamMetrics := []struct {
+		name  string
+		help  string
+		value func(batch *glance.Batch) float64
+	}{
+		{"stream_fps", "Last FPS of the stream.", func(b *glance.Batch) float64 { return b.Fps }},
+		{"stream_bitrate_kbps", "Last bitrate of the stream.", func(b *glance.Batch) float64 { return b.Bitrate }},
+		{"stream_height", "Last frame height of the stream.", func(b *glance.Batch) float64 { return float64(b.Height) }},
+		{"stream_keyframe_interval", "Last keyframe interval of the stream in frames.", func(b *glance.Batch) float64 {
+			return float64(b.KeyframeInterval)
+		}},
+		{"stream_audio_present", "1 if the stream had audio frames during the last interval.", func(b *glance.Batch) float64 {
+			return float64(b.HasAudio)
+		}},
+		{"stream_audio_bitrate_kbps", "Last audio bitrate of the stream.", func(b *glance.Batch) float64 { return b.AudioBitrate }},
+		{"stream_audio_gaps", "Audio packet gaps of the stream during the last interval.", func(b *glance.Batch) float64 {
+			return float64(b.AudioGaps)
+		}},
+	}
+
+	for _, metric := range streamMetrics {
+		family(w, metric.name, "gauge", metric.help)
+		for i := range batches {
+			point(w, metric.name, []string{"stream_id", batches[i].batch.StreamID}, metric.value(&batches[i].batch))
+		}
+	}
+
+	family(w, "stream_http_status_code", "gauge", "Last HTTP status code of the stream.")
+	for i := range statuses {
+		point(w, "stream_http_status_code", []string{"stream_id", statuses[i].id}, float64(statuses[i].code))
+	}
+}
+
+// activeStreams returns the IDs of the streams that have tasks in the pools of the workspaces
+func (e *Exporter) activeStreams() map[string]bool {
+	active := map[string]bool{}
+	for _, workspace := range e.workstation.WorkstationInformation().Workspaces {
+		for i := range workspace.Processes {
+			active[workspace.Processes[i].ID] = true
+		}
+	}
+
+	return active
+}
+
+// expire removes the values that have not been updated for longer than StreamTTL, the caller must hold the lock
+func (e *Exporter) expire(samples map[string]sample) {
+	if e.options.StreamTTL <= 0 {
+		return
+	}
+
+	for id, s := range samples {
+		if time.Since(s.updatedAt) > e.options.StreamTTL {
+			delete(samples, id)
+		}
+	}
+}
+
+type identifiedSample struct {
+	sample
+	id string
+}
+
+func sortedSamples(samples map[string]sample) []identifiedSample {
+	sorted := make([]identifiedSample, 0, len(samples))
+	for id, s := range samples {
+		sorted = append(sorted, identifiedSample{sample: s, id: id})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].id < sorted[j].id
+	})
+
+	return sorted
+}
+
+func family(w *bufio.Writer, name, kind, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, kind)
+}
+
+// point writes one value, labels are passed as a flat list of name and value pairs
+func point(w *bufio.Writer, name string, labels []string, value float64) {
+	_, _ = fmt.Fprintf(w, "%s_%s", namespace, name)
+
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escape(labels[i+1])))
+		}
+
+		_, _ = fmt.Fprintf(w, "{%s}", strings.Join(pairs, ","))
+	}
+
+	_, _ = fmt.Fprintf(w, " %v\n", value)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
@@ -0,0 +1,132 @@
+package prometheus
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/proc"
+	"github.com/zikwall/glance/pkg/scheduler/httpstat"
+)
+
+type MockWorker struct{}
+
+type mockChild struct{}
+
+func (c *mockChild) Pid() int {
+	return 42
+}
+
+func (c *mockChild) Kill() error {
+	return nil
+}
+
+func (w *MockWorker) Perform(ctx context.Context, _ glance.WorkerStream) {
+	untrack := glance.TrackProcess(ctx, &mockChild{})
+	defer untrack()
+
+	<-ctx.Done()
+}
+
+func (w *MockWorker) Name() string {
+	return "mock_worker"
+}
+
+func (w *MockWorker) Label() string {
+	return "mock_worker/"
+}
+
+func TestExporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workstation := glance.NewWithOptions(ctx, &glance.WorkspaceOptions{
+		Resources: &glance.ResourceOptions{
+			Interval: time.Millisecond * 10,
+			Sampler: func(pid int) (proc.Usage, error) {
+				return proc.Usage{CPUTime: time.Second * 2, RSS: 2048}, nil
+			},
+		},
+	}, &MockWorker{})
+	workspace, err := workstation.Workspace("mock_worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := workspace.PerformAsync(glance.WorkerItem{ID: "1", URL: "in"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := New(workstation, nil)
+
+	<-time.After(time.Millisecond * 50)
+
+	batch := glance.CreateBatch("1", glance.Frame{Frames: 50, Bytes: 1024, Seconds: 2, Height: 720, KeyframeInterval: 50})
+	if err := exporter.Storage(nil).ProcessFrameBatch(&batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exporter.StatusWriter(nil).Write(httpstat.Bucket{StreamID: "1", Code: 404}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should be expose metrics in text format", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		body, err := ioutil.ReadAll(recorder.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			"# TYPE glance_uptime_seconds gauge",
+			`glance_workspace_tasks{workspace="mock_worker",state="active"} 1`,
+			`glance_workspace_tasks{workspace="mock_worker",state="queued"} 0`,
+			`glance_workspace_restarts_total{workspace="mock_worker"} 0`,
+			`glance_task_restarts{workspace="mock_worker",stream_id="1"} 0`,
+			`glance_task_cpu_seconds_total{workspace="mock_worker",stream_id="1"} 2`,
+			`glance_task_rss_bytes{workspace="mock_worker",stream_id="1"} 2048`,
+			`glance_stream_fps{stream_id="1"} 25`,
+			`glance_stream_height{stream_id="1"} 720`,
+			`glance_stream_keyframe_interval{stream_id="1"} 50`,
+			`glance_stream_audio_present{stream_id="1"} 0`,
+			`glance_stream_http_status_code{stream_id="1"} 404`,
+		}
+
+		for _, line := range expected {
+			if !strings.Contains(string(body), line+"\n") {
+				t.Fatalf("Failed, expect line '%s' in:\n%s", line, body)
+			}
+		}
+	})
+
+	t.Run("it should be drop samples of streams without tasks", func(t *testing.T) {
+		if exporter.options.StreamTTL != DefaultStreamTTL {
+			t.Fatalf("Failed, expect default TTL, give %s", exporter.options.StreamTTL)
+		}
+
+		orphan := glance.CreateBatch("2", glance.Frame{Frames: 50, Bytes: 1024, Seconds: 2, Height: 720, KeyframeInterval: 50})
+		if err := exporter.Storage(nil).ProcessFrameBatch(&orphan); err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		body := recorder.Body.String()
+		if strings.Contains(body, `glance_stream_fps{stream_id="2"}`) || !strings.Contains(body, `glance_stream_fps{stream_id="1"}`) {
+			t.Fatalf("Failed, expect only stream with task, give:\n%s", body)
+		}
+	})
+
+	t.Run("it should be escape label values", func(t *testing.T) {
+		if escaped := escape("a\"b\\c\n"); escaped != `a\"b\\c\n` {
+			t.Fatalf("Failed, give %s", escaped)
+		}
+	})
+}
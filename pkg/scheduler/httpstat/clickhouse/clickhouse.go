@@ -4,6 +4,7 @@ import (
 	clickhousebuffer "github.com/zikwall/clickhouse-buffer"
 	"github.com/zikwall/clickhouse-buffer/src/buffer"
 
+	"github.com/zikwall/glance"
 	"github.com/zikwall/glance/pkg/scheduler/httpstat"
 )
 
@@ -25,11 +26,13 @@ func (c *writerImpl) Write(bucket httpstat.Bucket) error {
 type BucketAlias httpstat.Bucket
 
 func (b *BucketAlias) Row() buffer.RowSlice {
+	names, values := glance.LabelPairs(b.Labels)
+
 	return buffer.RowSlice{
-		b.StreamID, b.Code, b.InsertTS, b.InsertDate,
+		b.StreamID, b.Code, b.InsertTS, b.InsertDate, names, values,
 	}
 }
 
 func GetTableColumns() []string {
-	return []string{"stream_id", "code", "insert_ts", "insert_date"}
+	return []string{"stream_id", "code", "insert_ts", "insert_date", "labels.name", "labels.value"}
 }
@@ -12,14 +12,17 @@ import (
 )
 
 type future struct {
-	id   string
-	code int
-	err  error
+	id     string
+	code   int
+	err    error
+	labels map[string]string
 }
 
 type request struct {
-	id  string
-	url string
+	id      string
+	url     string
+	headers map[string]string
+	labels  map[string]string
 }
 
 func (r *request) RequestContext(ctx context.Context, url string, headers map[string]string) (int, error) {
@@ -66,11 +69,12 @@ func asyncRequests(ctx context.Context, count int, headers map[string]string, re
 					return
 				default:
 				}
-				code, err := request.RequestContext(ctx, request.url, headers)
+				code, err := request.RequestContext(ctx, request.url, mergeHeaders(headers, request.headers))
 				pool <- future{
-					id:   request.id,
-					code: code,
-					err:  err,
+					id:     request.id,
+					code:   code,
+					err:    err,
+					labels: request.labels,
 				}
 			}
 		}(n, r)
@@ -90,9 +94,10 @@ loop:
 			break loop
 		case value := <-pool:
 			values = append(values, Status{
-				ID:    value.id,
-				Code:  value.code,
-				Error: value.err,
+				ID:     value.id,
+				Code:   value.code,
+				Error:  value.err,
+				Labels: value.labels,
 			})
 		}
 	}
@@ -100,6 +105,23 @@ loop:
 	return values
 }
 
+// mergeHeaders returns the common headers of the scheduler overridden by the headers of the stream
+func mergeHeaders(common, stream map[string]string) map[string]string {
+	if len(stream) == 0 {
+		return common
+	}
+
+	merged := make(map[string]string, len(common)+len(stream))
+	for name, value := range common {
+		merged[name] = value
+	}
+	for name, value := range stream {
+		merged[name] = value
+	}
+
+	return merged
+}
+
 func parts(streams int) int {
 	return int(math.Round(float64(streams)/float64(threads) + 0.49))
 }
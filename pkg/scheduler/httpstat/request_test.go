@@ -56,4 +56,14 @@ func TestRequestContext(t *testing.T) {
 			t.Fatalf("Failed, expect 6 give %d", n)
 		}
 	})
+	t.Run("it should be override common headers by stream headers", func(t *testing.T) {
+		merged := mergeHeaders(
+			map[string]string{"User-Agent": "glance", "Authorization": "common"},
+			map[string]string{"Authorization": "stream"},
+		)
+
+		if merged["User-Agent"] != "glance" || merged["Authorization"] != "stream" {
+			t.Fatalf("Failed, give %v", merged)
+		}
+	})
 }
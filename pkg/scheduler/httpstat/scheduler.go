@@ -10,25 +10,33 @@ import (
 
 const threads = 3
 
+// OffAirLabel the label of the status of the stream checked outside its broadcast windows, see Options.AnnotateOffAir
+const OffAirLabel = "off_air"
+
 type Status struct {
-	ID    string
-	Code  int
-	Error error
+	ID     string
+	Code   int
+	Error  error
+	Labels map[string]string
 }
 
 type Scheduler struct {
-	fetcher glance.Fetcher
-	storage StatusWriter
-	options *Options
+	fetcher   glance.Fetcher
+	storage   StatusWriter
+	options   *Options
+	schedules *glance.Schedules
 }
 
 type Options struct {
 	HTTPHeaders map[string]string
 	Refresh     time.Duration
+	// AnnotateOffAir if it is set, the streams outside their broadcast windows (glance.OptionSchedule)
+	// are checked too, and their statuses are marked with the OffAirLabel, by default such streams are skipped
+	AnnotateOffAir bool
 }
 
 func NewScheduler(fetcher glance.Fetcher, storage StatusWriter, options *Options) *Scheduler {
-	scheduler := &Scheduler{fetcher: fetcher, storage: storage, options: options}
+	scheduler := &Scheduler{fetcher: fetcher, storage: storage, options: options, schedules: glance.NewSchedules()}
 	return scheduler
 }
 
@@ -52,7 +60,7 @@ func (s *Scheduler) RunContext(ctx context.Context) {
 
 			now := glance.Datetime(time.Now())
 			dat := glance.Date(time.Now())
-			statuses := getHTTPStatuses(ctx, streams, s.options.HTTPHeaders)
+			statuses := getHTTPStatuses(ctx, s.scheduled(streams, time.Now()), s.options.HTTPHeaders)
 
 			for _, status := range statuses {
 				if status.Error != nil {
@@ -65,6 +73,7 @@ func (s *Scheduler) RunContext(ctx context.Context) {
 					Code:       status.Code,
 					InsertTS:   now,
 					InsertDate: dat,
+					Labels:     status.Labels,
 				})
 				if err != nil {
 					log.Warning(err)
@@ -74,6 +83,36 @@ func (s *Scheduler) RunContext(ctx context.Context) {
 	}
 }
 
+// scheduled returns the streams to check at the moment: the streams inside their broadcast windows,
+// and the annotated streams outside the windows if Options.AnnotateOffAir is set
+func (s *Scheduler) scheduled(streams glance.Collection, moment time.Time) glance.Collection {
+	collection := glance.Collection{Streams: make(map[string]glance.WorkerItem, len(streams.Streams))}
+	for id, stream := range streams.Streams {
+		airing, err := s.schedules.OnAir(stream, moment)
+		if err != nil {
+			log.Warning(err)
+		}
+
+		switch {
+		case airing:
+			collection.Streams[id] = stream
+		case s.options.AnnotateOffAir:
+			labels := make(map[string]string, len(stream.Labels)+1)
+			for name, value := range stream.Labels {
+				labels[name] = value
+			}
+
+			labels[OffAirLabel] = "true"
+			stream.Labels = labels
+			collection.Streams[id] = stream
+		}
+	}
+
+	s.schedules.Retain(streams)
+
+	return collection
+}
+
 func getHTTPStatuses(ctx context.Context, streams glance.Collection, headers map[string]string) []Status {
 	th := make([][]request, threads)
 	cn := parts(len(streams.Streams))
@@ -89,8 +128,10 @@ func getHTTPStatuses(ctx context.Context, streams glance.Collection, headers map
 		}
 
 		th[index] = append(th[index], request{
-			id:  stream.GetID(),
-			url: stream.GetURL(),
+			id:      stream.GetID(),
+			url:     stream.GetURL(),
+			headers: stream.GetHeaders(),
+			labels:  stream.GetLabels(),
 		})
 	}
 
@@ -0,0 +1,38 @@
+package httpstat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+func TestScheduled(t *testing.T) {
+	// 2021-06-04 is Friday
+	moment := time.Date(2021, 6, 4, 21, 0, 0, 0, time.UTC)
+	streams := glance.Collection{Streams: map[string]glance.WorkerItem{
+		"1": {ID: "1", URL: "http://localhost/1"},
+		"2": {ID: "2", URL: "http://localhost/2", Labels: map[string]string{"tier": "free"}, Options: map[string]string{
+			glance.OptionSchedule: "0 18 * * fri 2h",
+			glance.OptionTimezone: "UTC",
+		}},
+	}}
+
+	t.Run("it should be skip streams outside their windows", func(t *testing.T) {
+		scheduled := NewScheduler(nil, nil, &Options{}).scheduled(streams, moment)
+		if len(scheduled.Streams) != 1 || !scheduled.Exist("1") {
+			t.Fatalf("Failed, expect only stream without schedule, give %v", scheduled.Streams)
+		}
+	})
+
+	t.Run("it should be annotate streams outside their windows", func(t *testing.T) {
+		scheduled := NewScheduler(nil, nil, &Options{AnnotateOffAir: true}).scheduled(streams, moment)
+		if len(scheduled.Streams) != 2 || scheduled.Streams["2"].Labels[OffAirLabel] != "true" {
+			t.Fatalf("Failed, expect annotated stream #2, give %v", scheduled.Streams)
+		}
+
+		if scheduled.Streams["1"].Labels[OffAirLabel] != "" || streams.Streams["2"].Labels[OffAirLabel] != "" {
+			t.Fatal("Failed, expect labels of fetched streams are not changed")
+		}
+	})
+}
@@ -9,4 +9,6 @@ type Bucket struct {
 	Code       int
 	InsertTS   string
 	InsertDate string
+	// Labels of the stream, see glance.DescribedStream
+	Labels map[string]string
 }
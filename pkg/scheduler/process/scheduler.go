@@ -2,57 +2,372 @@ package process
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/zikwall/glance"
 	"github.com/zikwall/glance/pkg/log"
 )
 
+// Selector decides which fetched streams are run by the workspace
+type Selector func(stream glance.WorkerItem) bool
+
+// Target the workspace reconciled with the fetcher, the workspace runs all fetched streams if the selector is nil
+type Target struct {
+	Workspace *glance.Workspace
+	Selector  Selector
+}
+
 type Options struct {
-	RefreshInterval     time.Duration
+	// RefreshInterval how often the streams are fetched and the workspaces are reconciled, by default one minute
+	RefreshInterval time.Duration
+	// WorkspaceScreenshot and WorkspaceMetrics are shortcuts for Targets without selectors
 	WorkspaceScreenshot *glance.Workspace
 	WorkspaceMetrics    *glance.Workspace
+	// Targets the workspaces reconciled with the fetcher
+	Targets []Target
+	// Workstation if it is set, all its workspaces are reconciled, including the ones registered at runtime
+	Workstation *glance.Workstation
+	// Selectors of the workspaces of the Workstation by the name of the workspace
+	Selectors map[string]Selector
+	// StateFile optional file, where the tasks of the workspaces are saved after each refresh.
+	// On start the tasks are resumed from the file and then reconciled with the fetcher once it answers
+	StateFile *glance.StateFile
+	// WindowInterval how often the broadcast windows of the streams (glance.OptionSchedule) are checked
+	// between refreshes, by default one minute
+	WindowInterval time.Duration
+}
+
+const (
+	defaultRefreshInterval = time.Minute
+	defaultWindowInterval  = time.Minute
+)
+
+// targets returns all reconciled workspaces, each workspace is returned once
+func (o *Options) targets() []Target {
+	var targets []Target
+	seen := map[*glance.Workspace]bool{}
+
+	add := func(target Target) {
+		if target.Workspace == nil || seen[target.Workspace] {
+			return
+		}
+
+		seen[target.Workspace] = true
+		targets = append(targets, target)
+	}
+
+	add(Target{Workspace: o.WorkspaceMetrics})
+	add(Target{Workspace: o.WorkspaceScreenshot})
+
+	for _, target := range o.Targets {
+		add(target)
+	}
+
+	if o.Workstation != nil {
+		for _, workspace := range o.Workstation.Workspaces() {
+			add(Target{Workspace: workspace, Selector: o.Selectors[workspace.Name()]})
+		}
+	}
+
+	return targets
+}
+
+// selects reports whether the stream is run by the workspace of the target
+func (t Target) selects(stream glance.WorkerItem) bool {
+	return t.Selector == nil || t.Selector(stream)
+}
+
+// selected returns the fetched streams that are run by the workspace of the target
+func (t Target) selected(fetched glance.Collection) glance.Collection {
+	if t.Selector == nil {
+		return fetched
+	}
+
+	collection := glance.Collection{Streams: make(map[string]glance.WorkerItem, len(fetched.Streams))}
+	for id, stream := range fetched.Streams {
+		if t.Selector(stream) {
+			collection.Streams[id] = stream
+		}
+	}
+
+	return collection
+}
+
+func (t Target) label() string {
+	return fmt.Sprintf("[SCHEDULER][%s WORKER]", strings.ToUpper(t.Workspace.Name()))
 }
 
 type Scheduler struct {
 	fetcher glance.Fetcher
+	// fetched the last fetched streams, including the ones outside their broadcast windows
+	fetched glance.Collection
+	// airing the IDs of the fetched streams that were inside their broadcast windows on the last refresh
+	airing map[string]bool
+	// schedules the parsed broadcast windows of the fetched streams
+	schedules *glance.Schedules
 }
 
 func NewScheduler(fetcher glance.Fetcher) *Scheduler {
-	scheduler := &Scheduler{fetcher: fetcher}
+	scheduler := &Scheduler{fetcher: fetcher, schedules: glance.NewSchedules()}
 	return scheduler
 }
 
 func (s *Scheduler) RunContext(ctx context.Context, options Options) {
-	s.justRun(ctx, options.WorkspaceMetrics, options.WorkspaceScreenshot)
+	if s.resume(options) {
+		s.reconcile(ctx, options)
+	} else {
+		s.justRun(ctx, options.targets())
+	}
+	s.save(options)
+
+	// if the fetcher pushes changes, they are applied immediately, and the polling remains as a periodic resync
+	changes := s.watch(ctx)
+
+	refreshInterval := options.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultRefreshInterval
+	}
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	windowInterval := options.WindowInterval
+	if windowInterval <= 0 {
+		windowInterval = defaultWindowInterval
+	}
+
+	windows := time.NewTicker(windowInterval)
+	defer windows.Stop()
 
 	defer log.Info("monitoring thread update scheduler is being terminated")
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(options.RefreshInterval):
+		case change, ok := <-changes:
+			if !ok {
+				log.Warning("[SCHEDULER] watching of streams is interrupted, it will be resumed after resync")
+				changes = nil
+				continue
+			}
+
+			s.apply(options, change)
+		case <-windows.C:
+			if s.windowsChanged() {
+				log.Info("[SCHEDULER] broadcast windows are changed")
+
+				s.refresh(options)
+				s.save(options)
+			}
+		case <-ticker.C:
 			log.Info("monitoring thread update scheduler is started")
 
-			fetchedJobs, err := s.fetcher.FetchStreams(ctx)
-			if err != nil {
-				log.Warning(err)
-				continue
+			s.reconcile(ctx, options)
+			s.save(options)
+
+			if changes == nil {
+				changes = s.watch(ctx)
 			}
-			if options.WorkspaceScreenshot != nil {
-				refresh("[SCHEDULER][SCREENSHOT WORKER]", options.WorkspaceScreenshot, fetchedJobs)
+		}
+	}
+}
+
+// watch subscribes to the changes of streams, returns nil if the fetcher does not implement glance.WatchableFetcher
+func (s *Scheduler) watch(ctx context.Context) <-chan glance.StreamChange {
+	watchable, ok := s.fetcher.(glance.WatchableFetcher)
+	if !ok {
+		return nil
+	}
+
+	changes, err := watchable.WatchStreams(ctx)
+	if err != nil {
+		if !errors.Is(err, glance.ErrorWatchIsNotSupported) {
+			log.Warning(err)
+		}
+
+		return nil
+	}
+
+	return changes
+}
+
+// apply applies the change of the stream to the workspaces incrementally
+func (s *Scheduler) apply(options Options, change glance.StreamChange) {
+	stream := change.Stream
+
+	if s.fetched.Streams == nil {
+		s.fetched.Streams = map[string]glance.WorkerItem{}
+	}
+
+	if change.Type == glance.StreamRemoved {
+		delete(s.fetched.Streams, stream.ID)
+	} else {
+		s.fetched.Streams[stream.ID] = stream
+	}
+
+	airing := s.airs(stream, time.Now())
+	if s.airing != nil {
+		if airing && change.Type != glance.StreamRemoved {
+			s.airing[stream.ID] = true
+		} else {
+			delete(s.airing, stream.ID)
+		}
+	}
+
+	for _, target := range options.targets() {
+		space := target.Workspace
+		active, ok := space.ActiveTask(stream.ID)
+
+		var err error
+		switch {
+		case change.Type == glance.StreamRemoved || !target.selects(stream) || !airing:
+			if ok {
+				err = space.FinishAsyncTask(stream.ID)
 			}
-			if options.WorkspaceMetrics != nil {
-				refresh("[SCHEDULER][METRICS WORKER]", options.WorkspaceMetrics, fetchedJobs)
+		case !ok:
+			if !space.IsPaused(stream.ID) {
+				err = space.PerformAsync(stream)
 			}
+		case active.Changed(stream):
+			err = space.RestartAsyncTask(stream)
+		}
+
+		if err != nil {
+			log.Warning(fmt.Sprintf("%s %s %s", target.label(), strings.ToUpper(string(change.Type)), err))
+		}
+	}
+
+	log.Info(fmt.Sprintf("[SCHEDULER] stream #%s %s", stream.ID, change.Type))
+}
+
+func (s *Scheduler) reconcile(ctx context.Context, options Options) {
+	fetchedJobs, err := s.fetcher.FetchStreams(ctx)
+	if err != nil {
+		log.Warning(err)
+		return
+	}
+
+	s.remember(fetchedJobs)
+	s.refresh(options)
+}
+
+// remember keeps the copy of the fetched streams, the fetchers may return their cached collections,
+// which must not be changed by the applied changes
+func (s *Scheduler) remember(fetched glance.Collection) {
+	s.fetched = glance.Collection{Streams: make(map[string]glance.WorkerItem, len(fetched.Streams))}
+	for id, stream := range fetched.Streams {
+		s.fetched.Streams[id] = stream
+	}
+
+	s.schedules.Retain(s.fetched)
+}
+
+// refresh reconciles the workspaces with the last fetched streams, which are inside their broadcast windows
+func (s *Scheduler) refresh(options Options) {
+	if s.fetched.Streams == nil {
+		return
+	}
+
+	airing := s.onAir(time.Now())
+	for _, target := range options.targets() {
+		refresh(target.label(), target.Workspace, target.selected(airing))
+	}
+}
+
+// onAir returns the last fetched streams that are inside their broadcast windows at the moment and remembers them
+func (s *Scheduler) onAir(moment time.Time) glance.Collection {
+	collection := glance.Collection{Streams: make(map[string]glance.WorkerItem, len(s.fetched.Streams))}
+	s.airing = make(map[string]bool, len(s.fetched.Streams))
+
+	for id, stream := range s.fetched.Streams {
+		if s.airs(stream, moment) {
+			collection.Streams[id] = stream
+			s.airing[id] = true
+		}
+	}
+
+	return collection
+}
+
+// windowsChanged reports whether any of the last fetched streams entered or left its broadcast window since the last refresh
+func (s *Scheduler) windowsChanged() bool {
+	if s.airing == nil {
+		return false
+	}
+
+	now := time.Now()
+	for id, stream := range s.fetched.Streams {
+		if s.airs(stream, now) != s.airing[id] {
+			return true
 		}
 	}
+
+	return false
+}
+
+// airs reports whether the stream is inside its broadcast windows, the stream with invalid schedule is always run,
+// the error of the schedule is reported once per change of the schedule
+func (s *Scheduler) airs(stream glance.WorkerItem, moment time.Time) bool {
+	airing, err := s.schedules.OnAir(stream, moment)
+	if err != nil {
+		log.Warning(err)
+	}
+
+	return airing
+}
+
+// resume starts the tasks saved in the state file, returns true if at least one task has been resumed
+func (s *Scheduler) resume(options Options) bool {
+	if options.StateFile == nil {
+		return false
+	}
+
+	state, err := options.StateFile.Load()
+	if err != nil {
+		log.Warning(err)
+		return false
+	}
+
+	resumed := 0
+	for _, target := range options.targets() {
+		restored, err := target.Workspace.Restore(state.Workspaces[target.Workspace.Name()])
+		if err != nil {
+			log.Warning(err)
+		}
+
+		resumed += restored
+	}
+
+	if resumed > 0 {
+		log.Info(fmt.Sprintf("[SCHEDULER] resumed %d tasks from the state file saved at %s", resumed, glance.Datetime(state.SavedAt)))
+	}
+
+	return resumed > 0
+}
+
+// save writes the tasks of the workspaces to the state file
+func (s *Scheduler) save(options Options) {
+	if options.StateFile == nil {
+		return
+	}
+
+	targets := options.targets()
+	workspaces := make([]*glance.Workspace, 0, len(targets))
+	for _, target := range targets {
+		workspaces = append(workspaces, target.Workspace)
+	}
+
+	if err := options.StateFile.Save(workspaces...); err != nil {
+		log.Warning(err)
+	}
 }
 
 func refresh(t string, space *glance.Workspace, fetched glance.Collection) {
 	spaced := space.ActiveTasks()
-	stopped, started := 0, 0
+	stopped, started, restarted := 0, 0, 0
 
 	// if is active
 	// if is not fetched
@@ -70,39 +385,61 @@ func refresh(t string, space *glance.Workspace, fetched glance.Collection) {
 	// if is not active
 	// if is fetched
 	// start
+	//
+	// if is active
+	// if URL, headers, labels or options are changed
+	// restart
 	for fetchedID, stream := range fetched.Streams {
-		// if not exists -> run async
-		if !spaced.Exist(fetchedID) {
+		active, ok := spaced.Streams[fetchedID]
+
+		// if not exists -> run async, unless it is paused
+		if !ok {
+			if space.IsPaused(fetchedID) {
+				continue
+			}
+
 			if err := space.PerformAsync(stream); err != nil {
 				log.Warning(fmt.Sprintf("%s STARTING %s", t, err))
 			} else {
 				started++
 			}
+
+			continue
+		}
+
+		if active.Changed(stream) {
+			if err := space.RestartAsyncTask(stream); err != nil {
+				log.Warning(fmt.Sprintf("%s RESTARTING %s", t, err))
+			} else {
+				restarted++
+			}
 		}
 	}
 
-	if started+stopped > 0 {
-		log.Info(fmt.Sprintf("%s started %d stoped %d", t, started, stopped))
+	if started+stopped+restarted > 0 {
+		log.Info(fmt.Sprintf("%s started %d stoped %d restarted %d", t, started, stopped, restarted))
 	} else {
 		log.Info(fmt.Sprintf("%s nothing to update", t))
 	}
 }
 
-func (s *Scheduler) justRun(ctx context.Context, monitoring, screenshot *glance.Workspace) {
-	streams, err := s.fetcher.FetchStreams(ctx)
+// justRun starts all fetched streams, which are inside their broadcast windows,
+// in the workspaces of the targets without reconciliation
+func (s *Scheduler) justRun(ctx context.Context, targets []Target) {
+	fetched, err := s.fetcher.FetchStreams(ctx)
 	if err != nil {
 		log.Warning(err)
 		return
 	}
-	for _, stream := range streams.Streams {
-		if monitoring != nil {
-			if err := monitoring.PerformAsync(stream); err != nil {
-				log.Warning(err)
+
+	s.remember(fetched)
+	for _, stream := range s.onAir(time.Now()).Streams {
+		for _, target := range targets {
+			if !target.selects(stream) || target.Workspace.IsPaused(stream.ID) {
+				continue
 			}
-		}
 
-		if screenshot != nil {
-			if err := screenshot.PerformAsync(stream); err != nil {
+			if err := target.Workspace.PerformAsync(stream); err != nil {
 				log.Warning(err)
 			}
 		}
@@ -0,0 +1,242 @@
+package process
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zikwall/glance"
+)
+
+type MockWorker struct{}
+
+func (w *MockWorker) Perform(ctx context.Context, _ glance.WorkerStream) {
+	<-ctx.Done()
+}
+
+func (w *MockWorker) Name() string {
+	return "mock_worker"
+}
+
+func (w *MockWorker) Label() string {
+	return "mock_worker/"
+}
+
+func TestRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workspace := glance.NewWorkspace(ctx, &MockWorker{})
+	refresh("[TEST]", workspace, glance.Collection{Streams: map[string]glance.WorkerItem{
+		"1": {ID: "1", URL: "rtmp://old/1"},
+		"2": {ID: "2", URL: "rtmp://old/2"},
+		"3": {ID: "3", URL: "rtmp://old/3", Labels: map[string]string{"tier": "free"}},
+	}})
+
+	t.Run("it should be restart tasks with changed descriptors", func(t *testing.T) {
+		refresh("[TEST]", workspace, glance.Collection{Streams: map[string]glance.WorkerItem{
+			"1": {ID: "1", URL: "rtmp://new/1"},
+			"3": {ID: "3", URL: "rtmp://old/3", Labels: map[string]string{"tier": "premium"}},
+		}})
+
+		<-time.After(time.Millisecond * 20)
+
+		active := workspace.ActiveTasks()
+		if len(active.Streams) != 2 || active.Exist("2") {
+			t.Fatalf("Failed, expect stopped task #2, give %v", active.Streams)
+		}
+
+		if active.Streams["1"].URL != "rtmp://new/1" {
+			t.Fatalf("Failed, expect new URL of task #1, give %s", active.Streams["1"].URL)
+		}
+
+		if active.Streams["3"].Labels["tier"] != "premium" {
+			t.Fatal("Failed, expect new labels of task #3")
+		}
+	})
+	t.Run("it should be skip paused tasks", func(t *testing.T) {
+		if err := workspace.Pause("1", "maintenance", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		refresh("[TEST]", workspace, glance.Collection{Streams: map[string]glance.WorkerItem{
+			"1": {ID: "1", URL: "rtmp://new/1"},
+			"3": {ID: "3", URL: "rtmp://old/3", Labels: map[string]string{"tier": "premium"}},
+		}})
+
+		if workspace.ActiveTasks().Exist("1") {
+			t.Fatal("Failed, expect paused task #1 is not started")
+		}
+	})
+}
+
+type MockFetcher struct {
+	collection glance.Collection
+}
+
+func (f *MockFetcher) FetchStreams(_ context.Context) (glance.Collection, error) {
+	return f.collection, nil
+}
+
+type ScreenshotWorker struct {
+	MockWorker
+}
+
+func (w *ScreenshotWorker) Name() string {
+	return "screenshot_worker"
+}
+
+func TestTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workstation := glance.New(ctx, &MockWorker{}, &ScreenshotWorker{})
+	metrics, _ := workstation.Workspace("mock_worker")
+	screenshots, _ := workstation.Workspace("screenshot_worker")
+
+	fetcher := &MockFetcher{collection: glance.Collection{Streams: map[string]glance.WorkerItem{
+		"1": {ID: "1", URL: "rtmp://localhost/1", Labels: map[string]string{"tier": "premium"}},
+		"2": {ID: "2", URL: "rtmp://localhost/2", Labels: map[string]string{"tier": "free"}},
+	}}}
+
+	options := Options{
+		WorkspaceMetrics: metrics,
+		Workstation:      workstation,
+		Selectors: map[string]Selector{
+			"screenshot_worker": func(stream glance.WorkerItem) bool {
+				return stream.Labels["tier"] == "premium"
+			},
+		},
+	}
+
+	t.Run("it should be return each workspace once", func(t *testing.T) {
+		if targets := options.targets(); len(targets) != 2 {
+			t.Fatalf("Failed, expect two targets, give %d", len(targets))
+		}
+	})
+
+	t.Run("it should be run selected streams in workspaces", func(t *testing.T) {
+		NewScheduler(fetcher).reconcile(ctx, options)
+
+		if metrics.NumberOfActiveAsyncTasks() != 2 {
+			t.Fatalf("Failed, expect all streams in metrics workspace, give %d", metrics.NumberOfActiveAsyncTasks())
+		}
+
+		if active := screenshots.ActiveTasks(); len(active.Streams) != 1 || !active.Exist("1") {
+			t.Fatalf("Failed, expect only premium stream in screenshot workspace, give %v", active.Streams)
+		}
+	})
+}
+
+type WatchableFetcher struct {
+	MockFetcher
+	changes chan glance.StreamChange
+}
+
+func (f *WatchableFetcher) WatchStreams(_ context.Context) (<-chan glance.StreamChange, error) {
+	return f.changes, nil
+}
+
+func TestWatchableFetcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workspace := glance.NewWorkspace(ctx, &MockWorker{})
+	fetcher := &WatchableFetcher{
+		MockFetcher: MockFetcher{collection: glance.Collection{Streams: map[string]glance.WorkerItem{
+			"1": {ID: "1", URL: "rtmp://localhost/1"},
+		}}},
+		changes: make(chan glance.StreamChange),
+	}
+
+	go NewScheduler(fetcher).RunContext(ctx, Options{RefreshInterval: time.Hour, WorkspaceMetrics: workspace})
+
+	expect := func(check func() bool, message string) {
+		deadline := time.Now().Add(time.Second)
+		for !check() {
+			if time.Now().After(deadline) {
+				t.Fatal(message)
+			}
+
+			<-time.After(time.Millisecond * 10)
+		}
+	}
+
+	t.Run("it should be apply pushed changes without waiting for refresh", func(t *testing.T) {
+		expect(func() bool { return workspace.ActiveTasks().Exist("1") }, "Failed, expect initially fetched task #1")
+
+		fetcher.changes <- glance.StreamChange{Type: glance.StreamAdded, Stream: glance.WorkerItem{ID: "2", URL: "rtmp://localhost/2"}}
+		expect(func() bool { return workspace.ActiveTasks().Exist("2") }, "Failed, expect added task #2")
+
+		fetcher.changes <- glance.StreamChange{Type: glance.StreamUpdated, Stream: glance.WorkerItem{ID: "2", URL: "rtmp://origin/2"}}
+		expect(func() bool {
+			stream, ok := workspace.ActiveTask("2")
+			return ok && stream.URL == "rtmp://origin/2"
+		}, "Failed, expect updated URL of task #2")
+
+		fetcher.changes <- glance.StreamChange{Type: glance.StreamRemoved, Stream: glance.WorkerItem{ID: "1"}}
+		expect(func() bool { return !workspace.ActiveTasks().Exist("1") }, "Failed, expect removed task #1")
+	})
+}
+
+func TestBroadcastWindows(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workspace := glance.NewWorkspace(ctx, &MockWorker{})
+	tomorrow := strings.ToLower(time.Now().UTC().Add(time.Hour * 24).Weekday().String()[:3])
+
+	fetcher := &MockFetcher{collection: glance.Collection{Streams: map[string]glance.WorkerItem{
+		"1": {ID: "1", URL: "rtmp://localhost/1"},
+		"2": {ID: "2", URL: "rtmp://localhost/2", Options: map[string]string{
+			glance.OptionSchedule: "0 0 * * " + tomorrow + " 24h",
+			glance.OptionTimezone: "UTC",
+		}},
+	}}}
+
+	scheduler := NewScheduler(fetcher)
+	options := Options{WorkspaceMetrics: workspace}
+
+	t.Run("it should be run only streams inside their windows", func(t *testing.T) {
+		scheduler.reconcile(ctx, options)
+
+		if active := workspace.ActiveTasks(); len(active.Streams) != 1 || !active.Exist("1") {
+			t.Fatalf("Failed, expect only stream without schedule, give %v", active.Streams)
+		}
+
+		if scheduler.windowsChanged() {
+			t.Fatal("Failed, expect unchanged windows")
+		}
+	})
+
+	t.Run("it should be stop streams that left their windows", func(t *testing.T) {
+		stream := fetcher.collection.Streams["2"]
+		stream.Options = nil
+		scheduler.apply(options, glance.StreamChange{Type: glance.StreamUpdated, Stream: stream})
+
+		if !workspace.ActiveTasks().Exist("2") {
+			t.Fatal("Failed, expect stream without schedule is started")
+		}
+
+		scheduler.fetched.Streams["1"] = glance.WorkerItem{ID: "1", URL: "rtmp://localhost/1", Options: map[string]string{
+			glance.OptionSchedule: "0 0 * * " + tomorrow + " 24h",
+			glance.OptionTimezone: "UTC",
+		}}
+
+		if !scheduler.windowsChanged() {
+			t.Fatal("Failed, expect changed windows")
+		}
+
+		scheduler.refresh(options)
+		<-time.After(time.Millisecond * 20)
+
+		if active := workspace.ActiveTasks(); len(active.Streams) != 1 || !active.Exist("2") {
+			t.Fatalf("Failed, expect stopped stream #1, give %v", active.Streams)
+		}
+
+		if fetcher.collection.Streams["2"].Options == nil {
+			t.Fatal("Failed, expect fetched collection is not changed")
+		}
+	})
+}
@@ -27,6 +27,8 @@ type Batch glance.Batch
 
 // nolint:(typecheck) // its OK
 func (b *Batch) Row() buffer.RowSlice {
+	names, values := glance.LabelPairs(b.Labels)
+
 	return buffer.RowSlice{
 		b.StreamID,
 		b.Bitrate,
@@ -36,8 +38,17 @@ func (b *Batch) Row() buffer.RowSlice {
 		b.Bytes,
 		b.Seconds,
 		b.KeyframeInterval,
+		b.HasAudio,
+		b.AudioSampleRate,
+		b.AudioChannels,
+		b.AudioBitrate,
+		b.AudioFrames,
+		b.AudioGaps,
+		b.AudioGapSeconds,
 		b.InsertTS,
 		b.Date,
+		names,
+		values,
 	}
 }
 
@@ -55,6 +66,60 @@ func GetTableColumns() []string {
 		"bytes",
 		"seconds",
 		"keyframe_interval",
+		"has_audio",
+		"audio_sample_rate",
+		"audio_channels",
+		"audio_bitrate",
+		"audio_frames",
+		"audio_gaps",
+		"audio_gap_seconds",
+		"insert_ts",
+		"date",
+		"labels.name",
+		"labels.value",
+	}
+}
+
+// StallWriter records the stalled tasks of the workspace, see glance.WorkspaceOptions.StallStorage
+type StallWriter struct {
+	writer clickhousebuffer.Writer
+}
+
+func NewStallWriter(writer clickhousebuffer.Writer) *StallWriter {
+	sw := &StallWriter{writer: writer}
+	return sw
+}
+
+func (s *StallWriter) ProcessStall(stall *glance.Stall) error {
+	bucket := Stall(*stall)
+	s.writer.WriteRow(&bucket)
+
+	return nil
+}
+
+type Stall glance.Stall
+
+func (s *Stall) Row() buffer.RowSlice {
+	return buffer.RowSlice{
+		s.StreamID,
+		s.Workspace,
+		s.Silence,
+		s.Attempt,
+		s.InsertTS,
+		s.Date,
+	}
+}
+
+func GetDefaultStallTableName() string {
+	return "stream.stalls"
+}
+
+func GetStallTableColumns() []string {
+	return []string{
+		"stream_id",
+		"workspace",
+		"silence",
+		"attempt",
 		"insert_ts",
 		"date",
 	}
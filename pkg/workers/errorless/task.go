@@ -1,6 +1,9 @@
 package errorless
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type TaskNotFoundError struct {
 	ID string
@@ -25,3 +28,56 @@ func (e *TaskAlreadyExistsError) Error() string {
 func TaskAlreadyExists(id string) *TaskAlreadyExistsError {
 	return &TaskAlreadyExistsError{id}
 }
+
+type TaskPanicError struct {
+	ID    string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *TaskPanicError) Error() string {
+	return fmt.Sprintf("task: %s crashed with panic: %v", e.ID, e.Value)
+}
+
+func TaskPanic(id string, value interface{}, stack []byte) *TaskPanicError {
+	return &TaskPanicError{ID: id, Value: value, Stack: stack}
+}
+
+type TaskStalledError struct {
+	ID      string
+	Silence time.Duration
+}
+
+func (e *TaskStalledError) Error() string {
+	return fmt.Sprintf("task: %s stalled, no output for %s", e.ID, e.Silence)
+}
+
+func TaskStalled(id string, silence time.Duration) *TaskStalledError {
+	return &TaskStalledError{ID: id, Silence: silence}
+}
+
+type ResourceBudgetExceededError struct {
+	ID     string
+	Reason string
+}
+
+func (e *ResourceBudgetExceededError) Error() string {
+	return fmt.Sprintf("task: %s exceeded the resource budget: %s", e.ID, e.Reason)
+}
+
+func ResourceBudgetExceeded(id, reason string) *ResourceBudgetExceededError {
+	return &ResourceBudgetExceededError{ID: id, Reason: reason}
+}
+
+type TaskPausedError struct {
+	ID     string
+	Reason string
+}
+
+func (e *TaskPausedError) Error() string {
+	return fmt.Sprintf("task: %s is paused: %s", e.ID, e.Reason)
+}
+
+func TaskPaused(id, reason string) *TaskPausedError {
+	return &TaskPausedError{ID: id, Reason: reason}
+}
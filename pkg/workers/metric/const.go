@@ -1,4 +1,21 @@
 package metric
 
-const partialSize = 6
-const partialSizeWithBrokenSideData = partialSize + 2
+import "time"
+
+// entries the fields of the frames requested from ffprobe, both the old (pkt_pts_time, pkt_duration_time)
+// and the new (pts_time, duration_time) names are requested, ffprobe prints the ones it knows
+const entries = "frame=media_type,stream_index,key_frame,pts_time,pkt_pts_time,best_effort_timestamp_time," +
+	"duration_time,pkt_duration_time,pkt_size,height,nb_samples,channels"
+
+// streamEntries the fields of the first audio stream, which are read from its header before the frames,
+// the frames of ffprobe do not have the sample rate
+const streamEntries = "stream=sample_rate"
+
+// streamInfoTimeout how long the header of the audio stream is read
+const streamInfoTimeout = time.Second * 10
+
+const mediaVideo = "video"
+const mediaAudio = "audio"
+
+// DefaultAudioGapThreshold the minimal silence between the audio packets, which is counted as the gap
+const DefaultAudioGapThreshold = time.Millisecond * 100
@@ -2,46 +2,52 @@ package metric
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
+	"time"
 
+	"github.com/zikwall/glance"
 	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/proc"
 	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
 type process struct {
-	cmd *exec.Cmd
-	r   *io.PipeReader
-	w   *io.PipeWriter
-	f   *os.File
+	command *proc.Command
+	r       *io.PipeReader
+	w       *io.PipeWriter
+	f       *os.File
 }
 
-func (a *Worker) execute(rtmp, id string) (*process, error) {
-	file, err := ioutil.TempFile("./tmp", fmt.Sprintf("%s_go_tmp_stream_err_*.log", id))
+func (a *Worker) execute(stream glance.WorkerStream) (*process, error) {
+	file, err := ioutil.TempFile("./tmp", fmt.Sprintf("%s_go_tmp_stream_err_*.log", stream.GetID()))
 	if err != nil {
 		return nil, err
 	}
 
-	rt, err := url.Parse(rtmp)
+	rt, err := url.Parse(stream.GetURL())
 	if err != nil {
 		return nil, err
 	}
 
-	var args []string
-	for _, value := range a.options.HTTPHeaders {
-		args = append(args, "-headers", value)
+	args := proc.HeadersArgs(a.options.HTTPHeaders, glance.Headers(stream))
+	args = append(args, "-loglevel", "error", "-threads", "1")
+
+	// without the selection all streams are decoded, which is required only for the audio probing
+	if !a.probesAudio(stream) {
+		args = append(args, "-select_streams", "v:0")
 	}
+
 	args = append(args, []string{
-		"-loglevel", "error",
-		"-threads", "1",
-		"-select_streams", "v:0",
 		"-show_frames",
-		"-show_entries", "frame=key_frame,pkt_pts_time,pkt_size,height,repeat_pict",
-		"-of", "csv",
+		"-show_entries", entries,
+		"-of", "json=compact=1",
 		rt.String(),
 	}...)
 
@@ -50,11 +56,53 @@ func (a *Worker) execute(rtmp, id string) (*process, error) {
 	cmd.Stdout = w
 	cmd.Stderr = nil
 
-	if err := cmd.Start(); err != nil {
+	command, err := proc.Start(cmd)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+
 		return nil, err
 	}
 
-	return &process{cmd: cmd, r: r, w: w, f: file}, nil
+	return &process{command: command, r: r, w: w, f: file}, nil
+}
+
+// sampleRate reads the sample rate of the first audio stream with the separate short run of ffprobe,
+// because the streams section of the main run is printed only after all frames, that is never for the live stream.
+// Zero is returned if the stream has no audio
+func (a *Worker) sampleRate(ctx context.Context, stream glance.WorkerStream) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, streamInfoTimeout)
+	defer cancel()
+
+	args := proc.HeadersArgs(a.options.HTTPHeaders, glance.Headers(stream))
+	args = append(args, []string{
+		"-loglevel", "error",
+		"-select_streams", "a:0",
+		"-show_entries", streamEntries,
+		"-of", "json=compact=1",
+		stream.GetURL(),
+	}...)
+
+	output, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the audio stream: %w", err)
+	}
+
+	info := struct {
+		Streams []struct {
+			SampleRate value `json:"sample_rate"`
+		} `json:"streams"`
+	}{}
+
+	if err := json.Unmarshal(output, &info); err != nil {
+		return 0, fmt.Errorf("failed to parse the audio stream: %w", err)
+	}
+
+	if len(info.Streams) == 0 {
+		return 0, nil
+	}
+
+	return info.Streams[0].SampleRate.int(), nil
 }
 
 func (p *process) Reader() io.Reader {
@@ -75,10 +123,10 @@ func (p *process) clearResources() {
 	}
 }
 
-func (p *process) killProcesses(name, id string) {
-	if err := p.cmd.Process.Kill(); err != nil && !errorless.IsFinished(err) {
+func (p *process) killProcesses(name, id string, timeout time.Duration) {
+	if err := p.command.Terminate(timeout); err != nil && !errorless.IsFinished(err) {
 		errorless.Warning(name,
-			fmt.Sprintf("[#%s] failed to kill async process PID %d %s", id, p.cmd.Process.Pid, err),
+			fmt.Sprintf("[#%s] failed to kill async process PID %d %s", id, p.command.Pid(), err),
 		)
 	}
 }
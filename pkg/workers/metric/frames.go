@@ -0,0 +1,246 @@
+package metric
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/zikwall/glance"
+)
+
+// sample the frame reported by ffprobe
+type sample struct {
+	mediaType   string
+	streamIndex string
+	keyframe    bool
+	pts         float64
+	duration    float64
+	size        int
+	height      int
+	samples     int
+	channels    int
+}
+
+// value the field of the frame, ffprobe prints the same field as a number or as a string depending on its version
+type value string
+
+func (v *value) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*v = ""
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		*v = value(s)
+		return nil
+	}
+
+	*v = value(data)
+	return nil
+}
+
+func (v value) int() int {
+	return stringToInt(string(v))
+}
+
+func (v value) float() float64 {
+	return stringToFloat64(string(v))
+}
+
+// entry the frame in the JSON output of ffprobe, the unknown fields are ignored
+type entry struct {
+	MediaType               string `json:"media_type"`
+	StreamIndex             value  `json:"stream_index"`
+	KeyFrame                value  `json:"key_frame"`
+	PtsTime                 value  `json:"pts_time"`
+	PktPtsTime              value  `json:"pkt_pts_time"`
+	BestEffortTimestampTime value  `json:"best_effort_timestamp_time"`
+	DurationTime            value  `json:"duration_time"`
+	PktDurationTime         value  `json:"pkt_duration_time"`
+	PktSize                 value  `json:"pkt_size"`
+	Height                  value  `json:"height"`
+	NbSamples               value  `json:"nb_samples"`
+	Channels                value  `json:"channels"`
+}
+
+// first returns the first non-empty value, the fields are renamed in the newer versions of ffprobe
+func first(values ...value) value {
+	for _, v := range values {
+		if v != "" && v != "N/A" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// sample returns the frame of the video or audio, false for the other media types
+func (e *entry) sample() (sample, bool) {
+	if e.MediaType != mediaVideo && e.MediaType != mediaAudio {
+		return sample{}, false
+	}
+
+	s := sample{
+		mediaType:   e.MediaType,
+		streamIndex: string(e.StreamIndex),
+		keyframe:    isKeyframe(string(e.KeyFrame)),
+		pts:         first(e.PtsTime, e.PktPtsTime, e.BestEffortTimestampTime).float(),
+		duration:    first(e.DurationTime, e.PktDurationTime).float(),
+		size:        e.PktSize.int(),
+		height:      e.Height.int(),
+		samples:     e.NbSamples.int(),
+		channels:    e.Channels.int(),
+	}
+
+	return s, true
+}
+
+// frameDecoder reads the frames from the JSON output of ffprobe as they are printed: {"frames": [{...}, {...}]}
+type frameDecoder struct {
+	decoder *json.Decoder
+	inside  bool
+}
+
+func newFrameDecoder(r io.Reader) *frameDecoder {
+	d := &frameDecoder{decoder: json.NewDecoder(r)}
+	return d
+}
+
+// next returns the next frame of the video or audio, io.EOF when the list of frames is over
+func (d *frameDecoder) next() (sample, error) {
+	if !d.inside {
+		if err := d.seek(); err != nil {
+			return sample{}, err
+		}
+
+		d.inside = true
+	}
+
+	for d.decoder.More() {
+		e := entry{}
+		if err := d.decoder.Decode(&e); err != nil {
+			return sample{}, err
+		}
+
+		if s, ok := e.sample(); ok {
+			return s, nil
+		}
+	}
+
+	return sample{}, io.EOF
+}
+
+// seek skips the tokens until the beginning of the list of frames
+func (d *frameDecoder) seek() error {
+	for {
+		token, err := d.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if key, ok := token.(string); !ok || key != "frames" {
+			continue
+		}
+
+		token, err = d.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if delim, ok := token.(json.Delim); ok && delim == '[' {
+			return nil
+		}
+	}
+}
+
+// meter aggregates the frames of the first video and the first audio stream into batches,
+// the batch is completed by each keyframe of the video
+type meter struct {
+	id           string
+	gapThreshold float64
+
+	frame         glance.Frame
+	lastTimestamp float64
+	videoIndex    string
+	audioIndex    string
+	// audioEnd the end of the last audio frame, zero until the first audio frame
+	audioEnd float64
+	// sampleRate of the audio stream read from its header, zero until it is known
+	sampleRate int
+}
+
+func newMeter(id string, gapThreshold float64) *meter {
+	m := &meter{id: id, gapThreshold: gapThreshold}
+	return m
+}
+
+// add counts the frame, returns the batch if the frame completes the interval between keyframes
+func (m *meter) add(s sample) (glance.Batch, bool) {
+	if s.mediaType == mediaAudio {
+		m.addAudio(s)
+		return glance.Batch{}, false
+	}
+
+	if m.videoIndex == "" {
+		m.videoIndex = s.streamIndex
+	}
+
+	if s.streamIndex != m.videoIndex {
+		return glance.Batch{}, false
+	}
+
+	m.frame.IncreasingContinue(s.size)
+	if !s.keyframe {
+		return glance.Batch{}, false
+	}
+
+	m.frame.Height = s.height
+	pktPtsTime := math.Ceil(s.pts*1000000) / 1000000
+
+	seconds := math.Ceil((pktPtsTime-m.lastTimestamp)*1000000) / 1000000
+	m.frame.Seconds = seconds
+
+	var batch glance.Batch
+	completed := m.frame.Frames != 1
+	if completed {
+		batch = glance.CreateBatch(m.id, m.frame)
+	}
+
+	m.frame.Cleanup()
+	m.lastTimestamp = pktPtsTime
+
+	return batch, completed
+}
+
+func (m *meter) addAudio(s sample) {
+	if m.audioIndex == "" {
+		m.audioIndex = s.streamIndex
+	}
+
+	if s.streamIndex != m.audioIndex {
+		return
+	}
+
+	audio := &m.frame.Audio
+	audio.Increasing(s.size, s.samples, s.duration)
+	audio.Channels = s.channels
+	audio.SampleRate = m.sampleRate
+
+	if m.audioEnd > 0 {
+		if gap := s.pts - m.audioEnd; gap > m.gapThreshold {
+			audio.Gap(gap)
+		}
+	}
+
+	m.audioEnd = s.pts + s.duration
+}
+
+func isKeyframe(frame string) bool {
+	return frame == "1"
+}
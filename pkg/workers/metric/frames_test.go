@@ -0,0 +1,163 @@
+package metric
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zikwall/glance"
+)
+
+func decode(t *testing.T, output string) []sample {
+	var samples []sample
+
+	decoder := newFrameDecoder(strings.NewReader(output))
+	for {
+		s, err := decoder.next()
+		if err == io.EOF {
+			return samples
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples = append(samples, s)
+	}
+}
+
+func TestMeter(t *testing.T) {
+	t.Run("it should be decode frames of old and new versions of ffprobe", func(t *testing.T) {
+		output := `{
+    "frames": [
+        {"media_type": "video", "stream_index": 0, "key_frame": 1, "pkt_pts_time": "1.000000", "pkt_duration_time": "0.040000",
+         "pkt_size": "1500", "height": 720, "side_data_list": [{"side_data_type": "H.26[45] User Data Unregistered SEI message"}]},
+        {"media_type": "audio", "stream_index": 1, "key_frame": 1, "pts_time": "1.000000", "duration_time": "0.021333",
+         "pkt_size": "417", "nb_samples": 1024, "channels": 2, "new_field": {"nested": [1, 2, 3]}},
+        {"media_type": "subtitle", "stream_index": 2, "pts_time": "1.000000"},
+        {"media_type": "video", "stream_index": 0, "key_frame": "0", "best_effort_timestamp_time": "1.040000",
+         "pkt_pts_time": "N/A", "pkt_size": 1400, "height": "720"}
+    ]
+}`
+
+		samples := decode(t, output)
+		if len(samples) != 3 {
+			t.Fatalf("Failed, expect three frames, give %+v", samples)
+		}
+
+		video, audio := samples[0], samples[1]
+		if !video.keyframe || video.height != 720 || video.size != 1500 || video.pts != 1 || video.duration != 0.04 {
+			t.Fatalf("Failed, give %+v", video)
+		}
+
+		if audio.samples != 1024 || audio.channels != 2 || audio.pts != 1 || audio.duration != 0.021333 {
+			t.Fatalf("Failed, give %+v", audio)
+		}
+
+		if samples[2].keyframe || samples[2].pts != 1.04 || samples[2].size != 1400 {
+			t.Fatalf("Failed, expect fallback to best effort timestamp, give %+v", samples[2])
+		}
+	})
+
+	t.Run("it should be fail on truncated output", func(t *testing.T) {
+		decoder := newFrameDecoder(strings.NewReader(`{"frames": [{"media_type": "video", "stream_index": 0}, {"media_ty`))
+		if _, err := decoder.next(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := decoder.next(); err == nil || err == io.EOF {
+			t.Fatalf("Failed, expect decoding error, give %v", err)
+		}
+	})
+
+	t.Run("it should be aggregate audio between keyframes", func(t *testing.T) {
+		m := newMeter("1", 0.1)
+		m.sampleRate = 48000
+
+		var frames []string
+		for i := 0; i < 50; i++ {
+			keyframe := 0
+			if i == 0 {
+				keyframe = 1
+			}
+
+			frames = append(frames, fmt.Sprintf(
+				`{"media_type": "video", "stream_index": 0, "key_frame": %d, "pts_time": "%f", "pkt_size": "1000", "height": 720}`,
+				keyframe, float64(i)*0.04,
+			))
+		}
+
+		// 1.5 seconds of audio with the gap of 0.5 seconds
+		for i := 0; i < 94; i++ {
+			pts := float64(i) * 0.021333
+			if i >= 47 {
+				pts += 0.5
+			}
+
+			frames = append(frames, fmt.Sprintf(
+				`{"media_type": "audio", "stream_index": 1, "pts_time": "%f", "duration_time": "0.021333", "pkt_size": "384", `+
+					`"nb_samples": 1024, "channels": 2}`,
+				pts,
+			))
+		}
+
+		// the audio of the second track is ignored
+		frames = append(frames,
+			`{"media_type": "audio", "stream_index": 2, "pts_time": "0.5", "duration_time": "0.021333", "pkt_size": "384", `+
+				`"nb_samples": 1024, "channels": 6}`,
+			`{"media_type": "video", "stream_index": 0, "key_frame": 1, "pts_time": "2.0", "pkt_size": "1000", "height": 1080}`,
+		)
+
+		var batches int
+		for _, s := range decode(t, `{"frames": [`+strings.Join(frames, ",\n")+`]}`) {
+			batch, ok := m.add(s)
+			if !ok {
+				continue
+			}
+
+			batches++
+			if batch.HasAudio != 1 || batch.AudioChannels != 2 || batch.AudioSampleRate != 48000 || batch.AudioFrames != 94 {
+				t.Fatalf("Failed, give %+v", batch)
+			}
+
+			if batch.AudioGaps != 1 || batch.AudioGapSeconds != 0.5 || batch.AudioBitrate != 140.627 {
+				t.Fatalf("Failed, expect one gap and bitrate 140.627 kbps, give %+v", batch)
+			}
+		}
+
+		if batches != 1 {
+			t.Fatalf("Failed, expect one batch, give %d", batches)
+		}
+	})
+
+	t.Run("it should be report missing audio", func(t *testing.T) {
+		m := newMeter("1", 0.1)
+		m.add(sample{mediaType: mediaVideo, keyframe: true, pts: 0, size: 1000, height: 720})
+		m.add(sample{mediaType: mediaVideo, pts: 0.04, size: 1000, height: 720})
+
+		batch, ok := m.add(sample{mediaType: mediaVideo, keyframe: true, pts: 0.08, size: 1000, height: 720})
+		if !ok || batch.HasAudio != 0 || batch.AudioBitrate != 0 {
+			t.Fatalf("Failed, expect batch without audio, give %+v", batch)
+		}
+	})
+}
+
+func TestProbesAudio(t *testing.T) {
+	t.Run("it should be override audio probing per stream", func(t *testing.T) {
+		enabled := New("metric", nil, &Options{})
+		disabled := New("metric", nil, &Options{DisableAudio: true})
+
+		if !enabled.probesAudio(glance.WorkerItem{ID: "1"}) || disabled.probesAudio(glance.WorkerItem{ID: "1"}) {
+			t.Fatal("Failed, expect audio probing of the worker")
+		}
+
+		if enabled.probesAudio(glance.WorkerItem{ID: "1", Options: map[string]string{OptionAudio: "off"}}) {
+			t.Fatal("Failed, expect disabled audio probing of the stream")
+		}
+
+		if !disabled.probesAudio(glance.WorkerItem{ID: "1", Options: map[string]string{OptionAudio: "on"}}) {
+			t.Fatal("Failed, expect enabled audio probing of the stream")
+		}
+	})
+}
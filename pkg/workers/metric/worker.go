@@ -5,12 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/zikwall/glance"
 	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/proc"
 	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
@@ -22,6 +23,35 @@ type Worker struct {
 
 type Options struct {
 	HTTPHeaders []string
+	// TerminateTimeout how long the process has to complete after SIGTERM before it is killed,
+	// by default proc.DefaultTerminateTimeout
+	TerminateTimeout time.Duration
+	// AudioGapThreshold the minimal silence between the audio packets, which is counted as the gap,
+	// by default DefaultAudioGapThreshold
+	AudioGapThreshold time.Duration
+	// DisableAudio disables the audio probing, see OptionAudio. The audio frames are decoded by ffprobe,
+	// and without the selection of the stream ffprobe decodes all streams of the input, including the extra
+	// video programs of multi-program TS, so the probing of audio costs noticeably more CPU per task.
+	// Without audio probing ffprobe decodes only the first video stream, and the audio fields of the batches are zero
+	DisableAudio bool
+}
+
+// OptionAudio the per-stream option overriding Options.DisableAudio, "off" disables the audio probing of the stream,
+// "on" enables it
+const OptionAudio = "audio"
+
+// probesAudio reports whether the audio of the stream is probed
+func (w *Worker) probesAudio(stream glance.WorkerStream) bool {
+	if value, ok := glance.StreamOption(stream, OptionAudio); ok {
+		switch strings.ToLower(value) {
+		case "off", "false", "0":
+			return false
+		case "on", "true", "1":
+			return true
+		}
+	}
+
+	return !w.options.DisableAudio
 }
 
 func New(name string, storage glance.Storage, options *Options) *Worker {
@@ -40,120 +70,137 @@ func (w *Worker) Label() string {
 	return metric
 }
 
-// nolint:gocyclo // its OK cyclomatic complexity not important here
+// Heartbeats the worker sends the heartbeat for each frame, see glance.HeartbeatWorker
+func (w *Worker) Heartbeats() bool {
+	return true
+}
+
 func (w *Worker) Perform(ctx context.Context, stream glance.WorkerStream) {
+	_ = w.PerformWithExit(ctx, stream)
+}
+
+// PerformWithExit works like Perform, but returns the reason why the ffprobe process has completed
+// nolint:gocyclo // its OK cyclomatic complexity not important here
+func (w *Worker) PerformWithExit(ctx context.Context, stream glance.WorkerStream) error {
 	id := stream.GetID()
 
-	process, err := w.execute(stream.GetURL(), id)
+	process, err := w.execute(stream)
 	if err != nil {
 		errorless.Warning(w.Name(),
 			fmt.Sprintf("[#%s] async process will not be started, previous error: %s", id, err),
 		)
 
-		return
+		return err
 	}
 
+	untrack := glance.TrackProcess(ctx, process.command)
+
 	// If an asynchronous task fails with an ffmpeg process error,
 	// then there is no need to kill the process, since it has already been killed
 	// example give error - os: process already finished
 	NeedKillFFMPEG := true
 	defer func() {
+		defer untrack()
+
 		process.clearResources()
 		if NeedKillFFMPEG {
-			process.killProcesses(w.name, id)
+			process.killProcesses(w.name, id, proc.TerminateTimeout(glance.StreamOptions(stream), w.options.TerminateTimeout))
 		}
 	}()
 
-	EventReceiveFFMPEG := make(chan string, 1000)
+	EventReceiveFFMPEG := make(chan sample, 1000)
 	EventKillFFMPEG := make(chan error, 1)
+	EventDecodeFFMPEG := make(chan error, 1)
+	EventSampleRate := make(chan int, 1)
 	// Runs a separate sub-thread, because when running in a single thread,
 	// there is a lock while waiting for the buffer to be read.
 	// In turn blocking by the reader will not allow the background task to finish gracefully
 	go func() {
-		buffer := bufio.NewReader(process.r)
+		decoder := newFrameDecoder(bufio.NewReader(process.r))
 		for {
-			line, isPrefix, err := buffer.ReadLine()
+			s, err := decoder.next()
 			if err != nil {
-				if err != io.EOF {
-					errorless.Warning(w.Name(),
-						fmt.Sprintf("[#%s] reading from stdout completed (with error), cause %s", id, err))
+				// the output that can not be parsed further completes the attempt, so the restart policy handles it,
+				// instead of the task that is running without batches and heartbeats
+				if err != io.EOF && err != io.ErrClosedPipe {
+					EventDecodeFFMPEG <- err
 				}
 
 				return
 			}
 
-			str := string(line)
-			if isPrefix || str == "" {
-				continue
+			select {
+			case EventReceiveFFMPEG <- s:
+			case <-ctx.Done():
+				return
 			}
-
-			EventReceiveFFMPEG <- str
 		}
 	}()
 
+	// the sample rate is missing in the frames, so it is read from the header of the audio stream in parallel
+	if w.probesAudio(stream) {
+		go func() {
+			rate, err := w.sampleRate(ctx, stream)
+			if err != nil {
+				if ctx.Err() == nil {
+					errorless.Warning(w.Name(), fmt.Sprintf("[#%s] %s", id, err))
+				}
+
+				return
+			}
+
+			EventSampleRate <- rate
+		}()
+	}
+
 	// We listen to the FFMPEG process termination signal,
 	// this will provide an opportunity to remove the task from the pool and restart it if necessary
 	//
 	// Note: We listen to the context so as not to leave active goroutines when the task is completed
 	go func() {
 		select {
-		case EventKillFFMPEG <- process.cmd.Wait():
-			return
+		case <-process.command.Exited():
+			EventKillFFMPEG <- process.command.Err()
 		case <-ctx.Done():
-			return
 		}
 	}()
 
-	const keyframePos = 1
-	const timePos = 2
-	const bytesPos = 3
-	const heightPos = 4
+	gapThreshold := w.options.AudioGapThreshold
+	if gapThreshold <= 0 {
+		gapThreshold = DefaultAudioGapThreshold
+	}
 
-	frame := glance.Frame{}
-	var lastTimestamp float64
+	labels := glance.Labels(stream)
+	frames := newMeter(id, gapThreshold.Seconds())
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case err = <-EventKillFFMPEG:
 			NeedKillFFMPEG = false
 
 			if exitError, ok := err.(*exec.ExitError); ok {
-				err = fmt.Errorf("exit code is %d: %s", exitError.ExitCode(), exitError.Error())
+				err = fmt.Errorf("exit code is %d: %w", exitError.ExitCode(), exitError)
 			}
-			errorless.Warning(w.Name(), fmt.Sprintf(errorless.ProcessIsDie, id, process.cmd.Process.Pid, err))
-
-			return
-		case csvPartials := <-EventReceiveFFMPEG:
-			partials := strings.Split(csvPartials, ",")
-
-			if len(partials) == partialSize || len(partials) == partialSizeWithBrokenSideData {
-				frame.IncreasingContinue(
-					stringToInt(partials[bytesPos]),
-				)
-
-				if isKeyframe(partials[keyframePos]) {
-					frame.Height = stringToInt(partials[heightPos])
-					pktPtsTime := math.Ceil((stringToFloat64(partials[timePos]))*1000000) / 1000000
-
-					seconds := math.Ceil((pktPtsTime-lastTimestamp)*1000000) / 1000000
-					frame.Seconds = seconds
-
-					if frame.Frames != 1 {
-						batch := glance.CreateBatch(id, frame)
-						if err := w.storage.ProcessFrameBatch(&batch); err != nil {
-							log.Warning(err)
-						}
-					}
-
-					frame.Cleanup()
-					lastTimestamp = pktPtsTime
+			errorless.Warning(w.Name(), fmt.Sprintf(errorless.ProcessIsDie, id, process.command.Pid(), err))
+
+			return err
+		case err = <-EventDecodeFFMPEG:
+			err = fmt.Errorf("failed to parse output of ffprobe: %w", err)
+			errorless.Warning(w.Name(), fmt.Sprintf("[#%s] %s", id, err))
+
+			return err
+		case rate := <-EventSampleRate:
+			frames.sampleRate = rate
+		case s := <-EventReceiveFFMPEG:
+			glance.Heartbeat(ctx)
+
+			if batch, ok := frames.add(s); ok {
+				batch.Labels = labels
+				if err := w.storage.ProcessFrameBatch(&batch); err != nil {
+					log.Warning(err)
 				}
 			}
 		}
 	}
 }
-
-func isKeyframe(frame string) bool {
-	return frame == "1"
-}
@@ -6,17 +6,22 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"time"
 
+	"github.com/zikwall/glance"
 	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/proc"
 	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
 type process struct {
-	cmd  *exec.Cmd
-	temp *os.File
+	command *proc.Command
+	temp    *os.File
 }
 
-func (w *Worker) execute(rtmp, upload, id string) (*process, error) {
+func (w *Worker) execute(stream glance.WorkerStream, upload string) (*process, error) {
+	id := stream.GetID()
+
 	file, err := ioutil.TempFile("./tmp", fmt.Sprintf("%s_go_tmp_capture_*.log", id))
 	if err != nil {
 		return nil, err
@@ -27,7 +32,7 @@ func (w *Worker) execute(rtmp, upload, id string) (*process, error) {
 		return nil, err
 	}
 
-	rt, err := url.Parse(rtmp)
+	rt, err := url.Parse(stream.GetURL())
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +47,12 @@ func (w *Worker) execute(rtmp, upload, id string) (*process, error) {
 		"-nostdin",
 		"-threads", "1",
 		"-skip_frame", "nokey",
+	}...)
+
+	// the headers of the stream are the options of the input, so they are placed before it
+	args = append(args, proc.HeadersArgs(nil, glance.Headers(stream))...)
+
+	args = append(args, []string{
 		"-i", rt.String(),
 		"-vsync", "0",
 		"-r", "30",
@@ -63,14 +74,15 @@ func (w *Worker) execute(rtmp, upload, id string) (*process, error) {
 	cmd.Stdout = file
 	cmd.Stderr = file
 
-	if err := cmd.Start(); err != nil {
+	command, err := proc.Start(cmd)
+	if err != nil {
 		_ = file.Close()
 		_ = os.Remove(file.Name())
 
 		return nil, err
 	}
 
-	return &process{cmd: cmd, temp: file}, nil
+	return &process{command: command, temp: file}, nil
 }
 
 func (p *process) clearResources() {
@@ -83,10 +95,10 @@ func (p *process) clearResources() {
 	}
 }
 
-func (p *process) killProcesses(name, id string) {
-	if err := p.cmd.Process.Kill(); err != nil && !errorless.IsFinished(err) {
+func (p *process) killProcesses(name, id string, timeout time.Duration) {
+	if err := p.command.Terminate(timeout); err != nil && !errorless.IsFinished(err) {
 		errorless.Warning(name,
-			fmt.Sprintf("[#%s] failed to kill async process PID %d %s", id, p.cmd.Process.Pid, err),
+			fmt.Sprintf("[#%s] failed to kill async process PID %d %s", id, p.command.Pid(), err),
 		)
 	}
 }
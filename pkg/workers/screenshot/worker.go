@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"time"
 
 	"github.com/zikwall/glance"
+	"github.com/zikwall/glance/pkg/proc"
 	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
@@ -18,6 +20,9 @@ type Worker struct {
 
 type Options struct {
 	HTTPHeaders []string
+	// TerminateTimeout how long the process has to complete after SIGTERM before it is killed,
+	// by default proc.DefaultTerminateTimeout
+	TerminateTimeout time.Duration
 }
 
 func New(name, upload string, formatter URLFormatter, options *Options) *Worker {
@@ -39,48 +44,56 @@ func (w *Worker) Label() string {
 }
 
 func (w *Worker) Perform(ctx context.Context, stream glance.WorkerStream) {
+	_ = w.PerformWithExit(ctx, stream)
+}
+
+// PerformWithExit works like Perform, but returns the reason why the ffmpeg process has completed
+func (w *Worker) PerformWithExit(ctx context.Context, stream glance.WorkerStream) error {
 	id := stream.GetID()
 
-	process, err := w.execute(stream.GetURL(), w.upload, id)
+	process, err := w.execute(stream, w.upload)
 	if err != nil {
 		errorless.Warning(w.Name(),
 			fmt.Sprintf("[#%s] async process will not be started, previous error: %s", id, err),
 		)
 
-		return
+		return err
 	}
 
+	untrack := glance.TrackProcess(ctx, process.command)
+
 	NeedKillFFMPEG := true
 	defer func() {
+		defer untrack()
+
 		process.clearResources()
 		if NeedKillFFMPEG {
-			process.killProcesses(w.name, id)
+			process.killProcesses(w.name, id, proc.TerminateTimeout(glance.StreamOptions(stream), w.options.TerminateTimeout))
 		}
 	}()
 
 	EventKillFFMPEG := make(chan error, 1)
 	go func() {
 		select {
-		case EventKillFFMPEG <- process.cmd.Wait():
-			return
+		case <-process.command.Exited():
+			EventKillFFMPEG <- process.command.Err()
 		case <-ctx.Done():
-			return
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return
+		return nil
 	case err = <-EventKillFFMPEG:
 		NeedKillFFMPEG = false
 
 		if exitError, ok := err.(*exec.ExitError); ok {
-			err = fmt.Errorf("exit code is %d: %s", exitError.ExitCode(), exitError.Error())
+			err = fmt.Errorf("exit code is %d: %w", exitError.ExitCode(), exitError)
 		}
 		errorless.Warning(w.Name(),
-			fmt.Sprintf(errorless.ProcessIsDie, id, process.cmd.Process.Pid, err),
+			fmt.Sprintf(errorless.ProcessIsDie, id, process.command.Pid(), err),
 		)
 
-		return
+		return err
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	builder "github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -91,6 +92,12 @@ type SummaryResponse struct {
 	Label string `json:"label" db:"label"`
 }
 
+// LabelEquals the condition on the label of the stream stored in the Nested labels column,
+// for example query.Where(LabelEquals("tenant", "acme")) slices the dashboard by tenant
+func LabelEquals(name, value string) exp.LiteralExpression {
+	return builder.L("`labels.value`[indexOf(`labels.name`, ?)] = ?", name, value)
+}
+
 // BuildSummaryQuery Suitable for Pie-type charts
 func BuildSummaryQuery(from, to time.Time, valueExp, keyColumn, tableName string) *builder.SelectDataset {
 	query := builder.
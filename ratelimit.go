@@ -0,0 +1,157 @@
+package glance
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows rate launches per second on average and up to burst launches at once,
+// the buckets are guarded by the mutex of the limiter
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// delay refills the bucket and returns how long to wait until the token becomes available
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+// launchLimiter limits the rate of launches of workers in the workspace and to the same origin host
+type launchLimiter struct {
+	mu        sync.Mutex
+	workspace *tokenBucket
+	hosts     map[string]*tokenBucket
+	hostRate  float64
+	hostBurst int
+	swept     time.Time
+}
+
+func newLaunchLimiter(options *WorkspaceOptions) *launchLimiter {
+	limiter := &launchLimiter{
+		hosts:     map[string]*tokenBucket{},
+		hostRate:  options.HostLaunchRate,
+		hostBurst: options.HostLaunchBurst,
+		swept:     time.Now(),
+	}
+
+	if options.LaunchRate > 0 {
+		limiter.workspace = newTokenBucket(options.LaunchRate, options.LaunchBurst)
+	}
+
+	return limiter
+}
+
+// wait blocks until the launch of the stream is allowed, returns false if the context was completed earlier
+func (l *launchLimiter) wait(ctx context.Context, streamURL string) bool {
+	buckets := make([]*tokenBucket, 0, 2)
+	if l.workspace != nil {
+		buckets = append(buckets, l.workspace)
+	}
+
+	if host := l.host(streamURL); host != nil {
+		buckets = append(buckets, host)
+	}
+
+	if len(buckets) == 0 {
+		return true
+	}
+
+	for {
+		delay := l.take(buckets, time.Now())
+		if delay == 0 {
+			return true
+		}
+
+		if !sleepContext(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// take takes the token from each bucket if all of them have one, otherwise returns the longest delay
+// and takes nothing, so the bucket that is ready earlier does not lose its token while the launch waits for the other
+func (l *launchLimiter) take(buckets []*tokenBucket, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var delay time.Duration
+	for _, bucket := range buckets {
+		if d := bucket.delay(now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		return delay
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return 0
+}
+
+// host returns the bucket of the origin host of the stream, nil if the rate is not limited per host
+func (l *launchLimiter) host(streamURL string) *tokenBucket {
+	if l.hostRate <= 0 {
+		return nil
+	}
+
+	host := streamURL
+	if parsed, err := url.Parse(streamURL); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(time.Now())
+
+	bucket, ok := l.hosts[host]
+	if !ok {
+		bucket = newTokenBucket(l.hostRate, l.hostBurst)
+		l.hosts[host] = bucket
+	}
+
+	return bucket
+}
+
+// sweep removes the buckets of the hosts that have been refilled, such a bucket is no different from a new one,
+// so the hosts of the streams that are no longer launched are forgotten. The buckets are checked once per refill period
+func (l *launchLimiter) sweep(now time.Time) {
+	refill := time.Duration(math.Max(float64(l.hostBurst), 1) / l.hostRate * float64(time.Second))
+	if now.Sub(l.swept) < refill {
+		return
+	}
+
+	l.swept = now
+	for host, bucket := range l.hosts {
+		if bucket.delay(now) == 0 && bucket.tokens >= bucket.burst {
+			delete(l.hosts, host)
+		}
+	}
+}
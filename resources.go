@@ -0,0 +1,155 @@
+package glance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zikwall/glance/pkg/proc"
+	"github.com/zikwall/glance/pkg/workers/errorless"
+)
+
+const defaultResourceInterval = time.Second * 10
+
+// ResourceOptions configures the accounting of the resources of the child processes tracked by TrackProcess
+type ResourceOptions struct {
+	// Interval between samples, by default 10 seconds
+	Interval time.Duration
+	// MaxCPU the budget of CPU cores used by all child processes of the task, zero means no budget
+	MaxCPU float64
+	// MaxRSS the budget of resident memory in bytes of all child processes of the task, zero means no budget
+	MaxRSS uint64
+	// KillOverBudget the attempt of the task that exceeds the budget is killed and handled by the restart policy
+	KillOverBudget bool
+	// Sampler reads the usage of the process, by default proc.Sample
+	Sampler func(pid int) (proc.Usage, error)
+}
+
+// ResourceUsage the resources consumed by the child processes of the task
+type ResourceUsage struct {
+	// CPU cores used between the last two samples
+	CPU float64 `json:"cpu"`
+	// CPUTime total CPU time in seconds
+	CPUTime    float64 `json:"cpu_time"`
+	RSS        uint64  `json:"rss"`
+	ReadBytes  uint64  `json:"read_bytes"`
+	WriteBytes uint64  `json:"write_bytes"`
+	SampledAt  string  `json:"sampled_at"`
+	sampledAt  time.Time
+}
+
+func (w *Workspace) accountant() {
+	interval := w.options.Resources.Interval
+	if interval <= 0 {
+		interval = defaultResourceInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case <-ticker.C:
+			w.sampleResources()
+		}
+	}
+}
+
+type overBudget struct {
+	id      string
+	attempt int
+	err     error
+}
+
+func (w *Workspace) sampleResources() {
+	sampler := w.options.Resources.Sampler
+	if sampler == nil {
+		sampler = proc.Sample
+	}
+
+	type sample struct {
+		process *Process
+		usage   proc.Usage
+	}
+
+	// the files in /proc are read without the lock of the workspace
+	w.mu.RLock()
+	samples := make(map[string]sample, len(w.tasks))
+	for id, process := range w.tasks {
+		samples[id] = sample{process: process}
+	}
+	w.mu.RUnlock()
+
+	for id, s := range samples {
+		for _, child := range s.process.handle.list() {
+			usage, err := sampler(child.Pid())
+			if err != nil {
+				continue
+			}
+
+			s.usage = s.usage.Add(usage)
+		}
+
+		samples[id] = s
+	}
+
+	var exceeded []overBudget
+
+	w.mu.Lock()
+	now := time.Now()
+	for id, s := range samples {
+		if current, ok := w.tasks[id]; !ok || current != s.process {
+			continue
+		}
+
+		usage := ResourceUsage{
+			CPUTime:    s.usage.CPUTime.Seconds(),
+			RSS:        s.usage.RSS,
+			ReadBytes:  s.usage.ReadBytes,
+			WriteBytes: s.usage.WriteBytes,
+			SampledAt:  Datetime(now),
+			sampledAt:  now,
+		}
+
+		// CPU time decreases when the attempt is restarted with a new process
+		if previous := s.process.usage; previous != nil && usage.CPUTime >= previous.CPUTime {
+			if elapsed := now.Sub(previous.sampledAt).Seconds(); elapsed > 0 {
+				usage.CPU = (usage.CPUTime - previous.CPUTime) / elapsed
+			}
+		}
+
+		s.process.usage = &usage
+
+		if err := w.checkBudget(id, &usage); err != nil && s.process.state == TaskRunning && s.process.recycled == nil {
+			s.process.recycled = err
+			s.process.attemptCancel()
+			w.pushTransition(id, s.process, TaskOverBudget, err)
+			exceeded = append(exceeded, overBudget{id: id, attempt: s.process.attempts, err: err})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, e := range exceeded {
+		w.publish(Event{Type: EventTaskOverBudget, StreamID: e.id, State: TaskOverBudget, Attempt: e.attempt, Error: e.err})
+		errorless.Warning(w.worker.Name(), fmt.Sprintf("[#%s] %s, task will be recycled", e.id, e.err))
+	}
+}
+
+// checkBudget returns an error if the usage exceeds the budget and such tasks should be killed
+func (w *Workspace) checkBudget(id string, usage *ResourceUsage) error {
+	options := w.options.Resources
+	if !options.KillOverBudget {
+		return nil
+	}
+
+	if options.MaxCPU > 0 && usage.CPU > options.MaxCPU {
+		return errorless.ResourceBudgetExceeded(id, fmt.Sprintf("CPU %.2f of %.2f cores", usage.CPU, options.MaxCPU))
+	}
+
+	if options.MaxRSS > 0 && usage.RSS > options.MaxRSS {
+		return errorless.ResourceBudgetExceeded(id, fmt.Sprintf("RSS %d of %d bytes", usage.RSS, options.MaxRSS))
+	}
+
+	return nil
+}
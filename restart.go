@@ -0,0 +1,85 @@
+package glance
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const defaultBackoffMultiplier = 2
+
+// RestartPolicy describes how the Workspace restarts a task whose worker has completed on its own,
+// for example when the ffprobe/ffmpeg process died. The zero value disables restarts,
+// the task is simply removed from the pool and will be started again by the scheduler
+type RestartPolicy struct {
+	// MaxRetries the maximum number of consecutive restarts, after which the task is given up
+	MaxRetries int
+	// InitialBackoff delay before the first restart
+	InitialBackoff time.Duration
+	// MaxBackoff upper limit of the delay between restarts
+	MaxBackoff time.Duration
+	// Multiplier of the delay after each unsuccessful attempt, by default 2
+	Multiplier float64
+	// Jitter the fraction of the delay that is randomized, from 0 to 1
+	Jitter float64
+	// CoolDown time during which the exhausted task remains in the pool before it is given up,
+	// so that the scheduler does not start it again immediately
+	CoolDown time.Duration
+	// ResetAfter the counter of consecutive restarts is reset if the task worked longer than this duration
+	ResetAfter time.Duration
+}
+
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     defaultBackoffMultiplier,
+		Jitter:         0.2,
+		CoolDown:       time.Minute,
+		ResetAfter:     time.Minute * 5,
+	}
+}
+
+func (p RestartPolicy) enabled() bool {
+	return p.MaxRetries > 0
+}
+
+// Backoff returns the delay before the restart with the specified number, starting from 1
+func (p RestartPolicy) Backoff(restart int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(restart-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delta := delay * math.Min(p.Jitter, 1)
+		// nolint:gosec // its OK, jitter does not need a cryptographically secure generator
+		delay = delay - delta + rand.Float64()*2*delta
+	}
+
+	return time.Duration(delay)
+}
+
+// sleepContext waits for the specified duration, returns false if the context was completed earlier
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,318 @@
+package glance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OptionSchedule the per-stream option with the broadcast windows of the stream, outside the windows
+// the stream is not monitored. The windows are separated by semicolons, each window is the cron expression
+// of its start (minute, hour, day of month, month, day of week) followed by its duration,
+// for example "0 18 * * mon-fri 5h30m; 0 22 * * sat,sun 4h" (the weekend window crosses midnight)
+const OptionSchedule = "schedule"
+
+// OptionTimezone the per-stream option with the IANA time zone of OptionSchedule, for example "Europe/Moscow",
+// by default the local time zone of the process
+const OptionTimezone = "timezone"
+
+// maxWindowDuration the longest broadcast window, it bounds the search of the start of the window
+const maxWindowDuration = time.Hour * 24 * 7
+
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+var months = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// window the broadcast window, it starts at every minute matching the cron fields and lasts for the duration
+type window struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	// as in cron, if both days of month and days of week are restricted, the day matches either of them
+	anyDay     bool
+	anyWeekday bool
+	duration   time.Duration
+}
+
+// Schedule the broadcast windows of the stream
+type Schedule struct {
+	windows  []window
+	location *time.Location
+}
+
+// ParseSchedule parses the windows of OptionSchedule in the time zone, the empty time zone means the local one.
+// The cron fields support *, lists, ranges and steps, the names of months and days of week; 0 and 7 are Sunday
+func ParseSchedule(expression, timezone string) (*Schedule, error) {
+	schedule := &Schedule{location: time.Local}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+
+		schedule.location = location
+	}
+
+	for _, part := range strings.Split(expression, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		w, err := parseWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule window %q: %w", part, err)
+		}
+
+		schedule.windows = append(schedule.windows, w)
+	}
+
+	if len(schedule.windows) == 0 {
+		return nil, fmt.Errorf("schedule %q has no windows", expression)
+	}
+
+	return schedule, nil
+}
+
+func parseWindow(value string) (window, error) {
+	w := window{}
+
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) != 6 {
+		return w, fmt.Errorf("expected five cron fields and duration")
+	}
+
+	if err := parseField(fields[0], 0, 59, nil, w.minutes[:]); err != nil {
+		return w, err
+	}
+
+	if err := parseField(fields[1], 0, 23, nil, w.hours[:]); err != nil {
+		return w, err
+	}
+
+	if err := parseField(fields[2], 1, 31, nil, w.days[:]); err != nil {
+		return w, err
+	}
+
+	if err := parseField(fields[3], 1, 12, months, w.months[:]); err != nil {
+		return w, err
+	}
+
+	var weekdaysOf [8]bool
+	if err := parseField(fields[4], 0, 7, weekdays, weekdaysOf[:]); err != nil {
+		return w, err
+	}
+
+	copy(w.weekdays[:], weekdaysOf[:7])
+	w.weekdays[0] = w.weekdays[0] || weekdaysOf[7]
+	w.anyDay = strings.HasPrefix(fields[2], "*")
+	w.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	duration, err := time.ParseDuration(fields[5])
+	if err != nil {
+		return w, fmt.Errorf("invalid duration %q", fields[5])
+	}
+
+	if duration <= 0 || duration > maxWindowDuration {
+		return w, fmt.Errorf("duration %s is out of range (0, %s]", duration, maxWindowDuration)
+	}
+
+	w.duration = duration
+
+	return w, nil
+}
+
+// parseField sets the values of the cron field, for example "*", "*/15", "1-5", "mon-fri" or "0,30"
+func parseField(value string, min, max int, names map[string]int, set []bool) error {
+	for _, item := range strings.Split(value, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", item)
+			}
+
+			item = item[:i]
+		}
+
+		from, to := min, max
+		if item != "*" {
+			bounds := strings.Split(item, "-")
+			if len(bounds) > 2 {
+				return fmt.Errorf("invalid range %q", item)
+			}
+
+			var err error
+			if from, err = parseValue(bounds[0], min, max, names); err != nil {
+				return err
+			}
+
+			to = from
+			if len(bounds) == 2 {
+				if to, err = parseValue(bounds[1], min, max, names); err != nil {
+					return err
+				}
+			} else if step > 1 {
+				// as in cron, "10/5" means from 10 to the maximum with the step
+				to = max
+			}
+
+			if from > to {
+				return fmt.Errorf("invalid range %q", item)
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return nil
+}
+
+func parseValue(value string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[value]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	return v, nil
+}
+
+// starts reports whether the window starts at the minute
+func (w *window) starts(moment time.Time) bool {
+	if !w.minutes[moment.Minute()] || !w.hours[moment.Hour()] || !w.months[moment.Month()] {
+		return false
+	}
+
+	day, weekday := w.days[moment.Day()], w.weekdays[moment.Weekday()]
+	if w.anyDay || w.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+// Active reports whether the moment is inside one of the windows, that is, one of the windows started
+// no longer than its duration before the moment
+func (s *Schedule) Active(moment time.Time) bool {
+	moment = moment.In(s.location)
+	latest := moment.Add(-time.Duration(moment.Second())*time.Second - time.Duration(moment.Nanosecond()))
+
+	for i := range s.windows {
+		w := &s.windows[i]
+		for start := latest; moment.Sub(start) < w.duration; {
+			if !w.hours[start.Hour()] {
+				// skip to the last minute of the previous hour
+				start = start.Add(-time.Duration(start.Minute()+1) * time.Minute)
+				continue
+			}
+
+			if w.starts(start) {
+				return true
+			}
+
+			start = start.Add(-time.Minute)
+		}
+	}
+
+	return false
+}
+
+// StreamSchedule returns the schedule of the stream, nil if the stream does not set OptionSchedule
+func StreamSchedule(stream WorkerStream) (*Schedule, error) {
+	expression, ok := StreamOption(stream, OptionSchedule)
+	if !ok || strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+
+	timezone, _ := StreamOption(stream, OptionTimezone)
+	schedule, err := ParseSchedule(expression, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("stream %s: %w", stream.GetID(), err)
+	}
+
+	return schedule, nil
+}
+
+// OnAir reports whether the stream is inside its broadcast windows at the moment, the stream without schedule
+// is always on air. The stream with invalid schedule is also considered on air, so it is monitored anyway,
+// the error of the schedule is returned to be reported
+func OnAir(stream WorkerStream, moment time.Time) (bool, error) {
+	schedule, err := StreamSchedule(stream)
+	if err != nil {
+		return true, err
+	}
+
+	if schedule == nil {
+		return true, nil
+	}
+
+	return schedule.Active(moment), nil
+}
+
+// Schedules caches the parsed schedules of the streams by their ID, the schedule of the stream is parsed again
+// only when its OptionSchedule or OptionTimezone changes
+type Schedules struct {
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry
+}
+
+type scheduleEntry struct {
+	expression string
+	timezone   string
+	// schedule nil if the stream has no schedule or its schedule is invalid
+	schedule *Schedule
+}
+
+func NewSchedules() *Schedules {
+	schedules := &Schedules{entries: map[string]*scheduleEntry{}}
+	return schedules
+}
+
+// OnAir is like OnAir, but the error of the invalid schedule is returned only when the schedule is parsed,
+// so it is reported once per change of the schedule
+func (s *Schedules) OnAir(stream WorkerStream, moment time.Time) (bool, error) {
+	expression, _ := StreamOption(stream, OptionSchedule)
+	timezone, _ := StreamOption(stream, OptionTimezone)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	entry, ok := s.entries[stream.GetID()]
+	if !ok || entry.expression != expression || entry.timezone != timezone {
+		entry = &scheduleEntry{expression: expression, timezone: timezone}
+		entry.schedule, err = StreamSchedule(stream)
+		s.entries[stream.GetID()] = entry
+	}
+
+	if entry.schedule == nil {
+		return true, err
+	}
+
+	return entry.schedule.Active(moment), nil
+}
+
+// Retain forgets the schedules of the streams that are missing in the collection
+func (s *Schedules) Retain(collection Collection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.entries {
+		if !collection.Exist(id) {
+			delete(s.entries, id)
+		}
+	}
+}
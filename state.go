@@ -0,0 +1,101 @@
+package glance
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultHistorySize      = 10
+	defaultHistoryRetention = time.Hour * 24
+)
+
+// TaskState the state of an asynchronous task in the workspace
+type TaskState string
+
+const (
+	TaskStarting           TaskState = "starting"
+	TaskQueued             TaskState = "queued"
+	TaskRunning            TaskState = "running"
+	TaskCrashed            TaskState = "crashed"
+	TaskStalled            TaskState = "stalled"
+	TaskOverBudget         TaskState = "over-budget"
+	TaskBackingOff         TaskState = "backing-off"
+	TaskFailed             TaskState = "failed"
+	TaskStoppedByScheduler TaskState = "stopped-by-scheduler"
+	TaskStoppedByShutdown  TaskState = "stopped-by-shutdown"
+	TaskPaused             TaskState = "paused"
+)
+
+// IsStopped the task was stopped intentionally and will not be restarted by the workspace
+func (s TaskState) IsStopped() bool {
+	return s == TaskStoppedByScheduler || s == TaskStoppedByShutdown || s == TaskPaused
+}
+
+// Transition one change of the task state, the exit code and the error are filled in
+// when the worker has completed on its own
+type Transition struct {
+	State    TaskState `json:"state"`
+	At       string    `json:"at"`
+	Attempt  int       `json:"attempt"`
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"`
+	at       time.Time
+}
+
+type exitCoder interface {
+	ExitCode() int
+}
+
+// ExitCode extracts the exit code of the process from the error of the worker,
+// returns -1 if the error does not contain it
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var coder exitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	return -1
+}
+
+func newTransition(state TaskState, attempt int, err error) Transition {
+	now := time.Now()
+	transition := Transition{
+		State:    state,
+		At:       Datetime(now),
+		Attempt:  attempt,
+		ExitCode: ExitCode(err),
+		at:       now,
+	}
+
+	if err != nil {
+		transition.Error = err.Error()
+	}
+
+	return transition
+}
+
+// history bounded list of the last transitions of the task
+type history struct {
+	transitions []Transition
+}
+
+func (h *history) push(transition Transition, size int) {
+	h.transitions = append(h.transitions, transition)
+
+	if len(h.transitions) > size {
+		h.transitions = append(h.transitions[:0:0], h.transitions[len(h.transitions)-size:]...)
+	}
+}
+
+func (h *history) last() Transition {
+	return h.transitions[len(h.transitions)-1]
+}
+
+func (h *history) list() []Transition {
+	return append(make([]Transition, 0, len(h.transitions)), h.transitions...)
+}
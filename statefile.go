@@ -0,0 +1,173 @@
+package glance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TaskSnapshot the persisted state of the task, is used to resume the task after the restart of the application
+type TaskSnapshot struct {
+	ID       string            `json:"id"`
+	URL      string            `json:"url"`
+	Priority int               `json:"priority"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	State    TaskState         `json:"state"`
+	Attempts int               `json:"attempts"`
+	Failures int               `json:"failures"`
+	// RetryAt the time of the next launch of the task that was backing off
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// Snapshot returns the state of all tasks of the pool sorted by ID
+func (w *Workspace) Snapshot() []TaskSnapshot {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	snapshots := make([]TaskSnapshot, 0, len(w.tasks))
+	for id, process := range w.tasks {
+		item := Describe(process.stream)
+		snapshot := TaskSnapshot{
+			ID:       id,
+			URL:      item.URL,
+			Priority: process.priority,
+			Labels:   item.Labels,
+			Headers:  item.Headers,
+			Options:  item.Options,
+			State:    process.state,
+			Attempts: process.attempts,
+			Failures: process.failures,
+		}
+
+		if process.state == TaskBackingOff {
+			retryAt := process.retryAt
+			snapshot.RetryAt = &retryAt
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+
+	return snapshots
+}
+
+// Restore starts the tasks from the snapshots that are not in the pool yet, continuing their restart counters.
+// Returns the number of the started tasks
+func (w *Workspace) Restore(snapshots []TaskSnapshot) (int, error) {
+	restored := 0
+	for i := range snapshots {
+		snapshot := snapshots[i]
+		if w.lookupAsyncTask(snapshot.ID) {
+			continue
+		}
+
+		stream := WorkerItem{
+			ID:       snapshot.ID,
+			URL:      snapshot.URL,
+			Priority: snapshot.Priority,
+			Labels:   snapshot.Labels,
+			Headers:  snapshot.Headers,
+			Options:  snapshot.Options,
+		}
+		if err := w.attach(stream, &snapshot); err != nil {
+			return restored, err
+		}
+
+		restored++
+	}
+
+	return restored, nil
+}
+
+// State the content of the state file
+type State struct {
+	SavedAt    time.Time                 `json:"saved_at"`
+	Workspaces map[string][]TaskSnapshot `json:"workspaces"`
+}
+
+// StateFile persists the snapshots of workspaces to the local file, so that the last known set of streams
+// can be resumed after the restart of the application, even if the fetcher is not available at that moment
+type StateFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewStateFile(path string) *StateFile {
+	file := &StateFile{path: path}
+	return file
+}
+
+// Save writes the snapshots of the workspaces, the file is replaced atomically,
+// so that the crash during saving does not corrupt the previous state
+func (f *StateFile) Save(workspaces ...*Workspace) error {
+	state := State{SavedAt: time.Now(), Workspaces: make(map[string][]TaskSnapshot, len(workspaces))}
+	for _, workspace := range workspaces {
+		state.Workspaces[workspace.Name()] = workspace.Snapshot()
+	}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	temp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+
+	if _, err := temp.Write(content); err != nil {
+		_ = temp.Close()
+		return err
+	}
+
+	if err := temp.Sync(); err != nil {
+		_ = temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), f.path)
+}
+
+// Load reads the state, the missing file is an empty state
+func (f *StateFile) Load() (State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := State{Workspaces: map[string][]TaskSnapshot{}}
+
+	content, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, fmt.Errorf("state file %s is corrupted: %w", f.path, err)
+	}
+
+	return state, nil
+}
@@ -0,0 +1,88 @@
+package glance
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChildProcess the process started by the worker for the task, for example ffprobe or ffmpeg.
+// Tracked processes are killed by the workspace if the task does not complete before the shutdown deadline
+type ChildProcess interface {
+	Pid() int
+	Kill() error
+}
+
+type taskContextKey struct{}
+
+// taskHandle is passed to the worker through the context of the task,
+// it collects the child processes and the heartbeats of the task
+type taskHandle struct {
+	mu        sync.Mutex
+	processes map[int]ChildProcess
+	// unix nanoseconds of the last heartbeat, is accessed atomically
+	heartbeat int64
+}
+
+func newTaskHandle() *taskHandle {
+	return &taskHandle{processes: map[int]ChildProcess{}}
+}
+
+func withTaskHandle(ctx context.Context, handle *taskHandle) context.Context {
+	return context.WithValue(ctx, taskContextKey{}, handle)
+}
+
+func taskHandleFrom(ctx context.Context) (*taskHandle, bool) {
+	handle, ok := ctx.Value(taskContextKey{}).(*taskHandle)
+	return handle, ok
+}
+
+// TrackProcess registers the child process of the task, the context must be the one passed to Worker.Perform.
+// The returned function must be called after the process has completed
+func TrackProcess(ctx context.Context, child ChildProcess) (untrack func()) {
+	handle, ok := taskHandleFrom(ctx)
+	if !ok {
+		return func() {}
+	}
+
+	pid := child.Pid()
+
+	handle.mu.Lock()
+	handle.processes[pid] = child
+	handle.mu.Unlock()
+
+	return func() {
+		handle.mu.Lock()
+		delete(handle.processes, pid)
+		handle.mu.Unlock()
+	}
+}
+
+// Heartbeat reports that the task is alive and produces output, for example when the worker has parsed a frame.
+// The context must be the one passed to Worker.Perform
+func Heartbeat(ctx context.Context) {
+	if handle, ok := taskHandleFrom(ctx); ok {
+		handle.beat(time.Now())
+	}
+}
+
+func (h *taskHandle) beat(at time.Time) {
+	atomic.StoreInt64(&h.heartbeat, at.UnixNano())
+}
+
+func (h *taskHandle) lastHeartbeat() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&h.heartbeat))
+}
+
+func (h *taskHandle) list() []ChildProcess {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	processes := make([]ChildProcess, 0, len(h.processes))
+	for _, child := range h.processes {
+		processes = append(processes, child)
+	}
+
+	return processes
+}
@@ -2,7 +2,9 @@ package glance
 
 import (
 	"context"
+	"errors"
 	"math"
+	"sort"
 	"time"
 )
 
@@ -16,6 +18,31 @@ type Fetcher interface {
 	FetchStreams(ctx context.Context) (Collection, error)
 }
 
+// StreamChangeType the type of the change of the stream source
+type StreamChangeType string
+
+const (
+	StreamAdded   StreamChangeType = "added"
+	StreamUpdated StreamChangeType = "updated"
+	StreamRemoved StreamChangeType = "removed"
+)
+
+// StreamChange the change of the stream source, only the ID of the stream is required for removal
+type StreamChange struct {
+	Type   StreamChangeType
+	Stream WorkerItem
+}
+
+// WatchableFetcher is an optional extension of the Fetcher interface, which pushes the changes of streams
+// as soon as they happen. The channel is closed when the watch is interrupted, the caller can watch again later
+type WatchableFetcher interface {
+	Fetcher
+	WatchStreams(ctx context.Context) (<-chan StreamChange, error)
+}
+
+// ErrorWatchIsNotSupported is returned by WatchStreams of the fetcher decorator, if the decorated fetcher does not watch
+var ErrorWatchIsNotSupported = errors.New("fetcher does not support watching of streams")
+
 // Worker A worker interface that provides a synchronous Perform method
 // for the ability to implement custom processing of an asynchronous task.
 type Worker interface {
@@ -24,11 +51,110 @@ type Worker interface {
 	Label() string
 }
 
+// ExitWorker is an optional extension of the Worker interface, which reports the reason for the completion
+// of the task, for example, the exit error of the ffprobe/ffmpeg process.
+// If the worker implements it, the Workspace calls PerformWithExit instead of Perform
+type ExitWorker interface {
+	PerformWithExit(context.Context, WorkerStream) error
+}
+
+// HeartbeatWorker is an optional extension of the Worker interface, the worker that reports true calls Heartbeat
+// while its task makes progress. The watchdog of the workspace (WorkspaceOptions.StallTimeout) is started only
+// for such workers, the tasks of other workers would be recycled on every StallTimeout
+type HeartbeatWorker interface {
+	Heartbeats() bool
+}
+
+// sendsHeartbeats reports whether the worker implements HeartbeatWorker and reports true
+func sendsHeartbeats(worker Worker) bool {
+	heartbeat, ok := worker.(HeartbeatWorker)
+	return ok && heartbeat.Heartbeats()
+}
+
 type WorkerStream interface {
 	GetID() string
 	GetURL() string
 }
 
+// PrioritizedStream is an optional extension of the WorkerStream interface,
+// streams with a higher priority are the first to leave the admission queue of the workspace
+type PrioritizedStream interface {
+	GetPriority() int
+}
+
+// Priority returns the priority of the stream, zero if the stream does not implement PrioritizedStream
+func Priority(stream WorkerStream) int {
+	if prioritized, ok := stream.(PrioritizedStream); ok {
+		return prioritized.GetPriority()
+	}
+
+	return 0
+}
+
+// DescribedStream is an optional extension of the WorkerStream interface, which carries the descriptor of the stream:
+// labels (tenant, channel, region, tier) that flow into stored rows, HTTP headers for requests to the stream
+// and per-stream options of workers, the keys of the options are documented by each worker
+type DescribedStream interface {
+	GetLabels() map[string]string
+	GetHeaders() map[string]string
+	GetOptions() map[string]string
+}
+
+// Labels returns the labels of the stream, nil if the stream does not implement DescribedStream
+func Labels(stream WorkerStream) map[string]string {
+	if described, ok := stream.(DescribedStream); ok {
+		return described.GetLabels()
+	}
+
+	return nil
+}
+
+// Headers returns the HTTP headers of the stream, nil if the stream does not implement DescribedStream
+func Headers(stream WorkerStream) map[string]string {
+	if described, ok := stream.(DescribedStream); ok {
+		return described.GetHeaders()
+	}
+
+	return nil
+}
+
+// LabelPairs returns the names and the values of the labels sorted by name,
+// for example for storing them in the Nested column of ClickHouse
+func LabelPairs(labels map[string]string) (names, values []string) {
+	names = make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values = make([]string, 0, len(labels))
+	for _, name := range names {
+		values = append(values, labels[name])
+	}
+
+	return names, values
+}
+
+// StreamOptions returns the per-stream options of workers, nil if the stream does not implement DescribedStream
+func StreamOptions(stream WorkerStream) map[string]string {
+	if described, ok := stream.(DescribedStream); ok {
+		return described.GetOptions()
+	}
+
+	return nil
+}
+
+// StreamOption returns the per-stream option of the worker, false if the stream does not set it
+func StreamOption(stream WorkerStream, key string) (string, bool) {
+	described, ok := stream.(DescribedStream)
+	if !ok {
+		return "", false
+	}
+
+	value, ok := described.GetOptions()[key]
+	return value, ok
+}
+
 // Batch type is the main structure for generating and sending data to the storage
 type Batch struct {
 	Date             string  `json:"date"`
@@ -41,6 +167,17 @@ type Batch struct {
 	Frames           uint64  `json:"frames"`
 	Height           uint64  `json:"height"`
 	KeyframeInterval uint64  `json:"keyframe_interval"`
+	// HasAudio 1 if the audio frames were received during the interval, 0 if the audio is dead or missing
+	HasAudio        uint8   `json:"has_audio"`
+	AudioSampleRate uint64  `json:"audio_sample_rate"`
+	AudioChannels   uint64  `json:"audio_channels"`
+	AudioBitrate    float64 `json:"audio_bitrate"`
+	AudioFrames     uint64  `json:"audio_frames"`
+	// AudioGaps the number of gaps between the audio packets and their total duration in seconds
+	AudioGaps       uint64  `json:"audio_gaps"`
+	AudioGapSeconds float64 `json:"audio_gap_seconds"`
+	// Labels of the stream, see DescribedStream
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // Frame types for counting frames and their parameters
@@ -50,6 +187,32 @@ type Frame struct {
 	Seconds          float64
 	Height           int
 	KeyframeInterval int
+	// Audio the audio frames received during the interval of the video frames
+	Audio AudioFrame
+}
+
+// AudioFrame types for counting audio frames and their parameters
+type AudioFrame struct {
+	Frames     int
+	Bytes      int
+	Samples    int
+	Seconds    float64
+	SampleRate int
+	Channels   int
+	Gaps       int
+	GapSeconds float64
+}
+
+func (a *AudioFrame) Increasing(bytes, samples int, seconds float64) {
+	a.Bytes += bytes
+	a.Samples += samples
+	a.Seconds += seconds
+	a.Frames++
+}
+
+func (a *AudioFrame) Gap(seconds float64) {
+	a.Gaps++
+	a.GapSeconds += seconds
 }
 
 func (f *Frame) IncreasingContinue(bytes int) {
@@ -64,6 +227,7 @@ func (f *Frame) Cleanup() {
 	f.Seconds = 0
 	f.Height = 0
 	f.KeyframeInterval = 0
+	f.Audio = AudioFrame{}
 }
 
 const bitsInBytes = 8
@@ -88,6 +252,27 @@ func CreateBatch(id string, frame Frame) Batch {
 	batch.Fps = math.Round(fps*100) / 100
 	bitrate := float64(frame.Bytes*bitsInBytes) / (frame.Seconds * bytesInKb)
 	batch.Bitrate = math.Round(bitrate*1000) / 1000
+
+	if audio := frame.Audio; audio.Frames > 0 {
+		batch.HasAudio = 1
+		batch.AudioFrames = uint64(audio.Frames)
+		batch.AudioChannels = uint64(audio.Channels)
+		batch.AudioSampleRate = uint64(audio.SampleRate)
+		batch.AudioGaps = uint64(audio.Gaps)
+		batch.AudioGapSeconds = math.Round(audio.GapSeconds*1000) / 1000
+
+		// the duration of the audio frames is more accurate, but it is not reported by all demuxers
+		seconds := audio.Seconds
+		if seconds <= 0 {
+			seconds = frame.Seconds
+		}
+
+		if seconds > 0 {
+			audioBitrate := float64(audio.Bytes*bitsInBytes) / (seconds * bytesInKb)
+			batch.AudioBitrate = math.Round(audioBitrate*1000) / 1000
+		}
+	}
+
 	return batch
 }
 
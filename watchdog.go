@@ -0,0 +1,99 @@
+package glance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/workers/errorless"
+)
+
+const minWatchdogInterval = time.Millisecond * 10
+
+// StallStorage is an optional storage for recording stalled tasks
+type StallStorage interface {
+	ProcessStall(stall *Stall) error
+}
+
+// Stall the record about the task that was recycled by the watchdog
+type Stall struct {
+	Date      string  `json:"date"`
+	InsertTS  string  `json:"insert_ts"`
+	StreamID  string  `json:"stream_id"`
+	Workspace string  `json:"workspace"`
+	Silence   float64 `json:"silence"`
+	Attempt   uint64  `json:"attempt"`
+}
+
+// watchdog periodically recycles the running tasks that have not sent a heartbeat for longer than StallTimeout
+func (w *Workspace) watchdog() {
+	interval := w.options.StallTimeout / 4
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.context.Done():
+			return
+		case <-ticker.C:
+			w.recycleStalledTasks()
+		}
+	}
+}
+
+func (w *Workspace) recycleStalledTasks() {
+	var stalls []Stall
+
+	w.mu.Lock()
+	for id, process := range w.tasks {
+		if process.state != TaskRunning || process.recycled != nil {
+			continue
+		}
+
+		silence := time.Since(process.handle.lastHeartbeat())
+		if silence < w.options.StallTimeout {
+			continue
+		}
+
+		process.recycled = errorless.TaskStalled(id, silence)
+		process.attemptCancel()
+		w.pushTransition(id, process, TaskStalled, process.recycled)
+
+		now := time.Now()
+		stalls = append(stalls, Stall{
+			Date:      Date(now),
+			InsertTS:  Datetime(now),
+			StreamID:  id,
+			Workspace: w.worker.Name(),
+			Silence:   silence.Seconds(),
+			Attempt:   uint64(process.attempts),
+		})
+	}
+	w.mu.Unlock()
+
+	for i := range stalls {
+		stall := &stalls[i]
+		silence := time.Duration(stall.Silence * float64(time.Second))
+
+		w.publish(Event{
+			Type:     EventTaskStalled,
+			StreamID: stall.StreamID,
+			State:    TaskStalled,
+			Attempt:  int(stall.Attempt),
+			Error:    errorless.TaskStalled(stall.StreamID, silence),
+		})
+		errorless.Warning(w.worker.Name(),
+			fmt.Sprintf("[#%s] no output for %s, task is stalled and will be recycled", stall.StreamID, silence),
+		)
+
+		if w.options.StallStorage != nil {
+			if err := w.options.StallStorage.ProcessStall(stall); err != nil {
+				log.Warning(err)
+			}
+		}
+	}
+}
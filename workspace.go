@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,63 +15,307 @@ import (
 
 const shutdownWaitDuration = time.Second * 5
 
-var ErrorShutdownWithoutGracefulCompletion = errors.New("shutdown completed without graceful completion")
+var (
+	ErrorShutdownWithoutGracefulCompletion = errors.New("shutdown completed without graceful completion")
+	ErrorWorkspaceIsDrained                = errors.New("workspace is drained and does not accept new tasks")
+)
+
+type WorkspaceOptions struct {
+	RestartPolicy RestartPolicy
+	// MaxConcurrentTasks the maximum number of simultaneously running workers, the rest of the tasks
+	// are waiting in the queue. Zero means no limit
+	MaxConcurrentTasks int
+	// HistorySize the number of last state transitions stored for each stream, by default 10
+	HistorySize int
+	// HistoryRetention how long the history of a stream that is no longer in the pool is stored, by default 24 hours
+	HistoryRetention time.Duration
+	// Events the bus to which lifecycle events are published, by default each workspace has its own bus
+	Events *EventBus
+	// ShutdownTimeout how long Drop waits for the completion of tasks, by default 5 seconds
+	ShutdownTimeout time.Duration
+	// StallTimeout the running task that has not sent a heartbeat for longer than this duration is considered stalled,
+	// it is recycled and handled by the restart policy. Zero disables the watchdog.
+	// The watchdog is started only for workers that implement HeartbeatWorker, for example the metric worker,
+	// so the same options can be applied to all workspaces of the workstation
+	StallTimeout time.Duration
+	// StallStorage optional storage for recording stalled tasks
+	StallStorage StallStorage
+	// Resources enables the accounting of the resources of the child processes, nil disables it
+	Resources *ResourceOptions
+	// LaunchRate the maximum number of launches of workers per second in the workspace,
+	// applies to the first launches and restarts of tasks. Zero means no limit
+	LaunchRate float64
+	// LaunchBurst the number of launches allowed at once, by default 1
+	LaunchBurst int
+	// HostLaunchRate the maximum number of launches per second to the same host of the stream URL,
+	// zero means no limit
+	HostLaunchRate float64
+	// HostLaunchBurst the number of launches to the same host allowed at once, by default 1
+	HostLaunchBurst int
+}
 
 type Workspace struct {
-	mu      sync.RWMutex
-	tasks   map[string]Process
-	worker  Worker
-	context context.Context
+	mu        sync.RWMutex
+	tasks     map[string]*Process
+	histories map[string]*history
+	pauses    map[string]*pause
+	// running the goroutines of the tasks that have not exited yet, including the tasks already removed from the pool
+	// by FinishAsyncTask, RestartAsyncTask or Pause, so that Drop can kill and report them at the deadline
+	running map[*Process]string
+	// total number of restarts of all tasks since the workspace was created
+	restarts  uint64
+	worker    Worker
+	options   WorkspaceOptions
+	admission *admission
+	launches  *launchLimiter
+	context   context.Context
+	cancel    context.CancelFunc
 	// This property simultaneously serves as a counter for asynchronous tasks
 	// and a mechanism for waiting/completing the task, for successful completion
 	wg sync.WaitGroup
 	// This property serves as a flag for successful completion of all asynchronous tasks
-	done chan struct{}
+	done     chan struct{}
+	dropOnce sync.Once
 }
 
 func NewWorkspace(ctx context.Context, worker Worker) *Workspace {
-	return &Workspace{
-		mu:      sync.RWMutex{},
-		tasks:   map[string]Process{},
-		worker:  worker,
-		context: ctx,
-		wg:      sync.WaitGroup{},
-		done:    make(chan struct{}),
+	return NewWorkspaceWithOptions(ctx, worker, nil)
+}
+
+func NewWorkspaceWithOptions(ctx context.Context, worker Worker, options *WorkspaceOptions) *Workspace {
+	if options == nil {
+		options = &WorkspaceOptions{}
+	}
+
+	opts := *options
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = defaultHistorySize
+	}
+
+	if opts.HistoryRetention <= 0 {
+		opts.HistoryRetention = defaultHistoryRetention
+	}
+
+	if opts.Events == nil {
+		opts.Events = NewEventBus()
+	}
+
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = shutdownWaitDuration
 	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	w := &Workspace{
+		mu:        sync.RWMutex{},
+		tasks:     map[string]*Process{},
+		histories: map[string]*history{},
+		pauses:    map[string]*pause{},
+		running:   map[*Process]string{},
+		worker:    worker,
+		options:   opts,
+		admission: newAdmission(opts.MaxConcurrentTasks),
+		launches:  newLaunchLimiter(&opts),
+		context:   ctx,
+		cancel:    cancel,
+		wg:        sync.WaitGroup{},
+		done:      make(chan struct{}),
+	}
+
+	if opts.StallTimeout > 0 && sendsHeartbeats(worker) {
+		go w.watchdog()
+	}
+
+	if opts.Resources != nil {
+		go w.accountant()
+	}
+
+	return w
 }
 
 // PerformAsync Initializes the task and runs it in the background.
 // The task is handled by a worker defined by the worker interface, where the Perform method is defined
 func (w *Workspace) PerformAsync(stream WorkerStream) error {
+	return w.attach(stream, nil)
+}
+
+// attach adds the task to the pool and starts it, the snapshot continues the restart counters of the resumed task
+func (w *Workspace) attach(stream WorkerStream, snapshot *TaskSnapshot) error {
 	id := stream.GetID()
-	if w.lookupAsyncTask(id) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// the check is performed under the lock, so that no task can be added after Drop has canceled the workspace
+	if w.context.Err() != nil {
+		return ErrorWorkspaceIsDrained
+	}
+
+	if _, ok := w.tasks[id]; ok {
 		return errorless.TaskAlreadyExists(id)
 	}
 
-	ctx, cancel := context.WithCancel(w.context)
+	if p, ok := w.pauses[id]; ok {
+		return errorless.TaskPaused(id, p.reason)
+	}
 
-	w.attach(id, Process{
+	handle := newTaskHandle()
+	ctx, cancel := context.WithCancel(withTaskHandle(w.context, handle))
+	process := &Process{
 		ctx:       ctx,
 		cancel:    cancel,
+		stream:    stream,
+		handle:    handle,
+		priority:  Priority(stream),
 		startedAt: time.Now(),
-	})
+		exited:    make(chan struct{}),
+	}
+
+	if snapshot != nil {
+		process.attempts = snapshot.Attempts
+		process.failures = snapshot.Failures
+		if snapshot.RetryAt != nil {
+			process.retryAt = *snapshot.RetryAt
+		}
+	}
+
+	w.tasks[id] = process
+	w.running[process] = id
+	w.pushTransition(id, process, TaskStarting, nil)
 
 	w.wg.Add(1)
-	go func(id string, str WorkerStream) {
+	go func(id string, process *Process) {
 		w.launchAsyncTaskMsg(id)
 		defer func() {
-			w.tryCancelAndDetach(id)
+			w.tryCancelAndDetach(id, process)
+
+			w.mu.Lock()
+			delete(w.running, process)
+			w.mu.Unlock()
+
+			close(process.exited)
 			w.wg.Done()
 			w.shutdownAsyncTaskMsg(id)
 		}()
 
-		// The method must work synchronously, otherwise it will be completed
-		w.worker.Perform(ctx, str)
-	}(id, stream)
+		w.run(id, process)
+	}(id, process)
 
 	return nil
 }
 
+// run performs the task synchronously and restarts it according to the restart policy of the workspace,
+// the task is completed when its context is canceled or the restart attempts are exhausted
+func (w *Workspace) run(id string, process *Process) {
+	policy := w.options.RestartPolicy
+
+	// the resumed task waits for the rest of the backoff that was interrupted by the restart of the application
+	if delay := time.Until(process.retryAt); delay > 0 {
+		w.transit(id, process, TaskBackingOff, nil)
+		if !sleepContext(process.ctx, delay) {
+			return
+		}
+	}
+
+	for {
+		// the throttled task does not hold the slot, so the tasks of other hosts are admitted meanwhile
+		if !w.launches.wait(process.ctx, process.stream.GetURL()) {
+			return
+		}
+
+		admitted := w.admission.acquire(process.ctx, process.priority, func() {
+			w.enqueue(id, process)
+		})
+		if !admitted {
+			return
+		}
+
+		ctx, launchedAt, attempt := w.countAttempt(id, process)
+		w.publish(Event{Type: EventTaskStarted, StreamID: id, State: TaskRunning, Attempt: attempt})
+
+		err := w.perform(ctx, id, process)
+		w.admission.release()
+
+		if recycled := w.completeAttempt(process); recycled != nil {
+			err = recycled
+		}
+
+		var crash *errorless.TaskPanicError
+		if errors.As(err, &crash) {
+			w.crashed(id, process, attempt, crash)
+		}
+
+		if process.ctx.Err() != nil {
+			return
+		}
+
+		exited := Event{Type: EventTaskExited, StreamID: id, State: TaskFailed, Attempt: attempt, Error: err, ExitCode: ExitCode(err)}
+		if !policy.enabled() {
+			w.transit(id, process, TaskFailed, err)
+			w.publish(exited)
+
+			return
+		}
+
+		restart := w.countFailure(process, time.Since(launchedAt))
+		if restart > policy.MaxRetries {
+			w.transit(id, process, TaskFailed, err)
+			w.publish(exited)
+			w.giveUpAsyncTaskMsg(id, policy.MaxRetries, policy.CoolDown)
+			sleepContext(process.ctx, policy.CoolDown)
+
+			return
+		}
+
+		delay := policy.Backoff(restart)
+		w.scheduleRestart(id, process, delay, err)
+
+		exited.State = TaskBackingOff
+		w.publish(exited)
+		w.publish(Event{Type: EventRestartScheduled, StreamID: id, State: TaskBackingOff, Attempt: attempt, Delay: delay})
+		w.restartAsyncTaskMsg(id, restart, delay)
+
+		if !sleepContext(process.ctx, delay) {
+			return
+		}
+	}
+}
+
+// perform launches the worker, the reason for completion is known only for workers implementing ExitWorker.
+// The panic of the worker is isolated within the task and is returned as an error,
+// note that panics in goroutines started by the worker itself cannot be recovered here
+func (w *Workspace) perform(ctx context.Context, id string, process *Process) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = errorless.TaskPanic(id, recovered, debug.Stack())
+		}
+	}()
+
+	// The method must work synchronously, otherwise it will be completed
+	if worker, ok := w.worker.(ExitWorker); ok {
+		return worker.PerformWithExit(ctx, process.stream)
+	}
+
+	w.worker.Perform(ctx, process.stream)
+
+	return nil
+}
+
+// crashed marks the task as crashed, after that it is handled by the restart policy like any other failure
+func (w *Workspace) crashed(id string, process *Process, attempt int, crash *errorless.TaskPanicError) {
+	w.transit(id, process, TaskCrashed, crash)
+	w.publish(Event{
+		Type:     EventTaskCrashed,
+		StreamID: id,
+		State:    TaskCrashed,
+		Attempt:  attempt,
+		Error:    crash,
+		ExitCode: ExitCode(crash),
+		Stack:    string(crash.Stack),
+	})
+	w.crashAsyncTaskMsg(id, crash)
+}
+
 // FinishAsyncTask The method terminates a specific asynchronous task by removing it from the task pool.
 func (w *Workspace) FinishAsyncTask(id string) error {
 	w.mu.Lock()
@@ -79,12 +325,28 @@ func (w *Workspace) FinishAsyncTask(id string) error {
 		return errorless.TaskNotFound(id)
 	}
 
+	w.pushTransition(id, w.tasks[id], TaskStoppedByScheduler, nil)
 	w.tasks[id].cancel()
 	delete(w.tasks, id)
 
 	return nil
 }
 
+// NumberOfQueuedAsyncTasks the number of tasks waiting for a free slot to launch the worker
+func (w *Workspace) NumberOfQueuedAsyncTasks() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	queued := 0
+	for _, process := range w.tasks {
+		if process.state == TaskQueued {
+			queued++
+		}
+	}
+
+	return queued
+}
+
 func (w *Workspace) NumberOfActiveAsyncTasks() int {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -92,21 +354,43 @@ func (w *Workspace) NumberOfActiveAsyncTasks() int {
 	return len(w.tasks)
 }
 
+// ActiveTasks returns the descriptors of all tasks of the pool
 func (w *Workspace) ActiveTasks() Collection {
 	w.mu.RLock()
 	collection := Collection{
 		Streams: make(map[string]WorkerItem, len(w.tasks)),
 	}
 
-	for id := range w.tasks {
-		collection.Streams[id] = WorkerItem{
-			ID: id,
-		}
+	for id, process := range w.tasks {
+		collection.Streams[id] = Describe(process.stream)
 	}
 	w.mu.RUnlock()
 	return collection
 }
 
+// ActiveTask returns the descriptor of the task of the pool
+func (w *Workspace) ActiveTask(id string) (WorkerItem, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	process, ok := w.tasks[id]
+	if !ok {
+		return WorkerItem{}, false
+	}
+
+	return Describe(process.stream), true
+}
+
+// RestartAsyncTask replaces the task with the new descriptor of the stream, for example when its URL has changed.
+// The previous task is stopped by the scheduler like in FinishAsyncTask
+func (w *Workspace) RestartAsyncTask(stream WorkerStream) error {
+	if err := w.FinishAsyncTask(stream.GetID()); err != nil {
+		return err
+	}
+
+	return w.PerformAsync(stream)
+}
+
 func (w *Workspace) lookupAsyncTask(id string) bool {
 	w.mu.RLock()
 	_, ok := w.tasks[id]
@@ -115,22 +399,139 @@ func (w *Workspace) lookupAsyncTask(id string) bool {
 	return ok
 }
 
-func (w *Workspace) attach(id string, process Process) {
+// Safe deletion from the pool, the task could already have been replaced by a new one with the same ID
+func (w *Workspace) tryCancelAndDetach(id string, process *Process) {
 	w.mu.Lock()
-	w.tasks[id] = process
+
+	// the context can be canceled without the scheduler only when the whole workspace is stopped
+	if process.ctx.Err() != nil && !process.state.IsStopped() {
+		w.pushTransition(id, process, TaskStoppedByShutdown, nil)
+	}
+
+	process.cancel()
+
+	if current, ok := w.tasks[id]; ok && current == process {
+		delete(w.tasks, id)
+	}
+
+	w.pruneHistories()
+	state, attempt := process.state, process.attempts
 	w.mu.Unlock()
+
+	if state.IsStopped() {
+		w.publish(Event{Type: EventTaskStopped, StreamID: id, State: state, Attempt: attempt})
+	}
 }
 
-// Safe deletion from the pool
-func (w *Workspace) tryCancelAndDetach(id string) {
+// countAttempt registers the next launch of the task and returns the context of the attempt, its time and number.
+// The context of the attempt can be canceled separately from the task, for example by the watchdog
+func (w *Workspace) countAttempt(id string, process *Process) (ctx context.Context, launchedAt time.Time, attempt int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if _, ok := w.tasks[id]; ok && w.tasks[id].ctx.Err() == nil {
-		w.tasks[id].cancel()
+	process.attempts++
+	process.launchedAt = time.Now()
+	if process.attempts > 1 {
+		w.restarts++
 	}
 
-	delete(w.tasks, id)
+	process.recycled = nil
+	process.usage = nil
+	process.handle.beat(process.launchedAt)
+	ctx, process.attemptCancel = context.WithCancel(process.ctx)
+	w.pushTransition(id, process, TaskRunning, nil)
+
+	return ctx, process.launchedAt, process.attempts
+}
+
+// completeAttempt releases the context of the attempt and returns the reason,
+// if the attempt was recycled by the watchdog or the resource accounting
+func (w *Workspace) completeAttempt(process *Process) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	process.attemptCancel()
+	if process.recycled != nil {
+		return process.recycled
+	}
+
+	return nil
+}
+
+// scheduleRestart marks the task as backing off until the next launch
+func (w *Workspace) scheduleRestart(id string, process *Process, delay time.Duration, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	process.retryAt = time.Now().Add(delay)
+	w.pushTransition(id, process, TaskBackingOff, err)
+}
+
+// enqueue marks the task as waiting for admission
+func (w *Workspace) enqueue(id string, process *Process) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	process.queuedAt = time.Now()
+	w.pushTransition(id, process, TaskQueued, nil)
+}
+
+func (w *Workspace) publish(event Event) {
+	event.Workspace = w.worker.Name()
+	w.options.Events.Publish(event)
+}
+
+// Subscribe to the lifecycle events of the workspace, see EventBus.Subscribe
+func (w *Workspace) Subscribe(buffer int) (events <-chan Event, unsubscribe func()) {
+	return w.options.Events.Subscribe(buffer)
+}
+
+func (w *Workspace) transit(id string, process *Process, state TaskState, err error) {
+	w.mu.Lock()
+	w.pushTransition(id, process, state, err)
+	w.mu.Unlock()
+}
+
+// pushTransition changes the state of the task and saves it to the history, the caller must hold the lock.
+// The stopped task is final, the late transitions of its goroutine are ignored
+func (w *Workspace) pushTransition(id string, process *Process, state TaskState, err error) {
+	if process.state.IsStopped() {
+		return
+	}
+
+	process.state = state
+
+	h, ok := w.histories[id]
+	if !ok {
+		h = &history{}
+		w.histories[id] = h
+	}
+
+	h.push(newTransition(state, process.attempts, err), w.options.HistorySize)
+}
+
+// pruneHistories removes the histories of streams that have not been in the pool for a long time,
+// the caller must hold the lock
+func (w *Workspace) pruneHistories() {
+	for id, h := range w.histories {
+		if _, ok := w.tasks[id]; !ok && time.Since(h.last().at) > w.options.HistoryRetention {
+			delete(w.histories, id)
+		}
+	}
+}
+
+// countFailure registers an unplanned completion of the task and returns the number of the next restart
+func (w *Workspace) countFailure(process *Process, uptime time.Duration) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if reset := w.options.RestartPolicy.ResetAfter; reset > 0 && uptime >= reset {
+		process.failures = 0
+	}
+
+	process.failures++
+
+	return process.failures
 }
 
 // messages
@@ -142,6 +543,20 @@ func (w *Workspace) shutdownAsyncTaskMsg(id string) {
 	log.Info(errorless.Labeled(w.worker.Name(), fmt.Sprintf("[#%s] finished, removed from pool", id)))
 }
 
+func (w *Workspace) restartAsyncTaskMsg(id string, restart int, delay time.Duration) {
+	errorless.Warning(w.worker.Name(), fmt.Sprintf("[#%s] completed unexpectedly, restart #%d in %s", id, restart, delay))
+}
+
+func (w *Workspace) crashAsyncTaskMsg(id string, crash *errorless.TaskPanicError) {
+	errorless.Warning(w.worker.Name(), fmt.Sprintf("[#%s] worker crashed with panic: %v\n%s", id, crash.Value, crash.Stack))
+}
+
+func (w *Workspace) giveUpAsyncTaskMsg(id string, retries int, coolDown time.Duration) {
+	errorless.Warning(w.worker.Name(),
+		fmt.Sprintf("[#%s] restart attempts (%d) are exhausted, task will be given up in %s", id, retries, coolDown),
+	)
+}
+
 func (w *Workspace) doneAllAsyncTasksMsg() {
 	log.Info(errorless.Labeled(w.worker.Name(), "all asynchronous tasks in workspace completed successfully!"))
 }
@@ -150,30 +565,102 @@ func (w *Workspace) Context() context.Context {
 	return w.context
 }
 
-// The method waits for graceful completion or crashes after a certain amount of time
-func (w *Workspace) await() error {
-	select {
-	case <-w.done:
-		return nil
-	case <-time.After(shutdownWaitDuration):
-		return ErrorShutdownWithoutGracefulCompletion
-	}
+// Name the name of the worker that serves the workspace
+func (w *Workspace) Name() string {
+	return w.worker.Name()
+}
+
+// Drain stops all tasks of the workspace and waits for their completion,
+// after that the workspace no longer accepts new tasks
+func (w *Workspace) Drain() error {
+	err := w.Drop()
+	w.publish(Event{Type: EventWorkspaceDrained, Error: err})
+
+	return err
+}
+
+// DropReport describes what did not complete cleanly when the workspace was stopped
+type DropReport struct {
+	Workspace string `json:"workspace"`
+	// Unfinished tasks that did not complete before the deadline
+	Unfinished []string `json:"unfinished"`
+	// Killed PIDs of the child processes killed with SIGKILL after the deadline
+	Killed []int `json:"killed"`
+}
+
+func (r DropReport) Clean() bool {
+	return len(r.Unfinished) == 0
 }
 
 // Drop This and subsequent methods implement the Notifier interface,
 // which is automatically terminated when the server is stopped.
 // Completion occurs synchronously,
 // which represents the possibility of waiting for the completion of all asynchronous tasks,
-// or an emergency termination
+// or an emergency termination after WorkspaceOptions.ShutdownTimeout
 func (w *Workspace) Drop() error {
-	go func() {
-		// wait all async tasks
-		w.wg.Wait()
-		// to inform about the successful completion of the task
-		w.done <- struct{}{}
-		w.doneAllAsyncTasksMsg()
-	}()
+	ctx, cancel := context.WithTimeout(context.Background(), w.options.ShutdownTimeout)
+	defer cancel()
+
+	_, err := w.DropContext(ctx)
+	return err
+}
+
+// DropContext cancels all tasks and waits for their completion until the deadline of the context.
+// The workers are expected to stop their child processes with SIGTERM, the processes that are still alive
+// at the deadline are killed with SIGKILL. The method is safe to call multiple times
+func (w *Workspace) DropContext(ctx context.Context) (DropReport, error) {
+	// under the lock, so that PerformAsync cannot add a task after the cancellation,
+	// and only then the waiting is started, the counter of the tasks must not grow while it is awaited
+	w.mu.Lock()
+	w.cancel()
+	w.mu.Unlock()
+
+	w.dropOnce.Do(func() {
+		go func() {
+			// wait all async tasks
+			w.wg.Wait()
+			// to inform about the successful completion of the task
+			close(w.done)
+			w.doneAllAsyncTasksMsg()
+		}()
+	})
+
+	report := DropReport{Workspace: w.worker.Name(), Unfinished: []string{}, Killed: []int{}}
 
 	// waiting for a message about the completion of tasks, or completing
-	return w.await()
+	select {
+	case <-w.done:
+		return report, nil
+	case <-ctx.Done():
+	}
+
+	unfinished := map[string]bool{}
+
+	w.mu.RLock()
+	for process, id := range w.running {
+		if !unfinished[id] {
+			unfinished[id] = true
+			report.Unfinished = append(report.Unfinished, id)
+		}
+
+		for _, child := range process.handle.list() {
+			if err := child.Kill(); err != nil {
+				errorless.Warning(w.worker.Name(), fmt.Sprintf("[#%s] failed to kill PID %d %s", id, child.Pid(), err))
+				continue
+			}
+
+			report.Killed = append(report.Killed, child.Pid())
+		}
+	}
+	w.mu.RUnlock()
+
+	sort.Strings(report.Unfinished)
+	sort.Ints(report.Killed)
+
+	// all tasks have exited between the deadline and the report
+	if report.Clean() {
+		return report, nil
+	}
+
+	return report, ErrorShutdownWithoutGracefulCompletion
 }
@@ -9,11 +9,16 @@ import (
 	"time"
 
 	"github.com/zikwall/glance/pkg/log"
+	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
 type WorkerItem struct {
-	ID  string
-	URL string
+	ID       string
+	URL      string
+	Priority int
+	Labels   map[string]string
+	Headers  map[string]string
+	Options  map[string]string
 }
 
 func (wi WorkerItem) GetID() string {
@@ -24,26 +29,110 @@ func (wi WorkerItem) GetURL() string {
 	return wi.URL
 }
 
+func (wi WorkerItem) GetPriority() int {
+	return wi.Priority
+}
+
+func (wi WorkerItem) GetLabels() map[string]string {
+	return wi.Labels
+}
+
+func (wi WorkerItem) GetHeaders() map[string]string {
+	return wi.Headers
+}
+
+func (wi WorkerItem) GetOptions() map[string]string {
+	return wi.Options
+}
+
+// Describe returns the descriptor of the stream with all optional extensions of WorkerStream
+func Describe(stream WorkerStream) WorkerItem {
+	if item, ok := stream.(WorkerItem); ok {
+		return item
+	}
+
+	item := WorkerItem{ID: stream.GetID(), URL: stream.GetURL(), Priority: Priority(stream)}
+	if described, ok := stream.(DescribedStream); ok {
+		item.Labels = described.GetLabels()
+		item.Headers = described.GetHeaders()
+		item.Options = described.GetOptions()
+	}
+
+	return item
+}
+
+// Changed reports whether the stream must be restarted to apply the other descriptor:
+// the URL, the headers, the labels or the options of the stream differ
+func (wi WorkerItem) Changed(other WorkerItem) bool {
+	return wi.URL != other.URL ||
+		!equalMaps(wi.Headers, other.Headers) ||
+		!equalMaps(wi.Labels, other.Labels) ||
+		!equalMaps(wi.Options, other.Options)
+}
+
+func equalMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 type Workstation struct {
 	spaces    map[string]*Workspace
 	mu        sync.RWMutex
+	context   context.Context
+	options   *WorkspaceOptions
 	startedAt time.Time
 }
 
 type Process struct {
-	ctx       context.Context
-	cancel    context.CancelFunc
-	startedAt time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+	stream WorkerStream
+	handle *taskHandle
+	// cancels the current launch of the worker without completing the task
+	attemptCancel context.CancelFunc
+	// the reason why the current launch was recycled by the watchdog or the resource accounting
+	recycled error
+	// the last sample of the resources of the child processes
+	usage      *ResourceUsage
+	state      TaskState
+	priority   int
+	startedAt  time.Time
+	queuedAt   time.Time
+	launchedAt time.Time
+	// total number of launches of the task worker
+	attempts int
+	// number of consecutive unplanned completions, is used by the restart policy
+	failures int
+	// the time of the next launch of the task that is backing off
+	retryAt time.Time
+	// closed when the goroutine of the task has exited and its children are stopped
+	exited chan struct{}
 }
 
 func New(ctx context.Context, workers ...Worker) *Workstation {
+	return NewWithOptions(ctx, nil, workers...)
+}
+
+// NewWithOptions creates a workstation whose workspaces are configured with the same options
+func NewWithOptions(ctx context.Context, options *WorkspaceOptions, workers ...Worker) *Workstation {
 	w := &Workstation{}
 	w.mu = sync.RWMutex{}
 	w.startedAt = time.Now()
+	w.context = ctx
+	w.options = withEvents(options, NewEventBus())
 	w.spaces = map[string]*Workspace{}
 
 	for _, worker := range workers {
-		w.spaces[worker.Name()] = NewWorkspace(ctx, worker)
+		w.spaces[worker.Name()] = NewWorkspaceWithOptions(ctx, worker, w.options)
 	}
 
 	return w
@@ -61,6 +150,83 @@ func (w *Workstation) Workspace(name string) (*Workspace, error) {
 	return workspace, nil
 }
 
+// Workspaces returns all workspaces of the workstation sorted by name
+func (w *Workstation) Workspaces() []*Workspace {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	workspaces := make([]*Workspace, 0, len(w.spaces))
+	for _, workspace := range w.spaces {
+		workspaces = append(workspaces, workspace)
+	}
+
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].Name() < workspaces[j].Name()
+	})
+
+	return workspaces
+}
+
+// withEvents returns a copy of the options with the event bus, if it is not already specified
+func withEvents(options *WorkspaceOptions, events *EventBus) *WorkspaceOptions {
+	opts := WorkspaceOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.Events == nil {
+		opts.Events = events
+	}
+
+	return &opts
+}
+
+// Subscribe to the lifecycle events of all workspaces of the workstation that share its event bus,
+// see EventBus.Subscribe
+func (w *Workstation) Subscribe(buffer int) (events <-chan Event, unsubscribe func()) {
+	return w.options.Events.Subscribe(buffer)
+}
+
+// Register creates a workspace for the worker on a running workstation,
+// if the options are not specified, the options of the workstation are used
+func (w *Workstation) Register(worker Worker, options *WorkspaceOptions) (*Workspace, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.spaces[worker.Name()]; ok {
+		return nil, fmt.Errorf("workspace '%s' already exists", worker.Name())
+	}
+
+	if options == nil {
+		options = w.options
+	}
+
+	workspace := NewWorkspaceWithOptions(w.context, worker, withEvents(options, w.options.Events))
+	w.spaces[worker.Name()] = workspace
+
+	return workspace, nil
+}
+
+// Unregister gracefully stops all tasks of the workspace and removes it from the workstation
+func (w *Workstation) Unregister(name string) error {
+	workspace, err := w.Workspace(name)
+	if err != nil {
+		return err
+	}
+
+	drainErr := workspace.Drain()
+
+	w.mu.Lock()
+	if w.spaces[name] == workspace {
+		delete(w.spaces, name)
+	}
+	w.mu.Unlock()
+
+	log.Info(errorless.Labeled(name, "workspace is drained and unregistered"))
+
+	return drainErr
+}
+
 type (
 	Info struct {
 		Workspaces map[string]WorkspaceInfo `json:"workspaces"`
@@ -70,11 +236,30 @@ type (
 		Name           string        `json:"name"`
 		Label          string        `json:"label"`
 		TotalProcesses int           `json:"total_processes"`
+		TotalQueued    int           `json:"total_queued"`
+		TotalFailed    int           `json:"total_failed"`
+		TotalRestarts  uint64        `json:"total_restarts"`
 		Processes      []ProcessInfo `json:"processes"`
+		// Queued tasks waiting for a free slot, in the order of admission
+		Queued []ProcessInfo `json:"queued"`
+		// Detached streams that are no longer in the pool, with the history of why they left it
+		Detached []ProcessInfo `json:"detached"`
+		// Paused streams, which are not started by the scheduler until they are resumed
+		Paused []PauseInfo `json:"paused"`
 	}
 	ProcessInfo struct {
-		Name      string `json:"name"`
-		StartedAt string `json:"started_at"`
+		ID         string            `json:"id"`
+		Name       string            `json:"name"`
+		State      TaskState         `json:"state"`
+		Priority   int               `json:"priority"`
+		Labels     map[string]string `json:"labels,omitempty"`
+		StartedAt  string            `json:"started_at,omitempty"`
+		LaunchedAt string            `json:"launched_at,omitempty"`
+		Attempts   int               `json:"attempts"`
+		Restarts   int               `json:"restarts"`
+		History    []Transition      `json:"history"`
+		// Resources of the child processes, if the resource accounting is enabled
+		Resources *ResourceUsage `json:"resources,omitempty"`
 	}
 	RuntimeInfo struct {
 		NumGC       uint32  `json:"num_gc"`
@@ -87,8 +272,20 @@ type (
 func (w *Workstation) WorkstationInformation() Info {
 	info := Info{
 		Workspaces: map[string]WorkspaceInfo{},
+		Runtime:    w.RuntimeInformation(),
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, workspace := range w.spaces {
+		info.Workspaces[workspace.worker.Name()] = workspace.Information()
 	}
 
+	return info
+}
+
+func (w *Workstation) RuntimeInformation() RuntimeInfo {
 	memory := runtime.MemStats{}
 	runtime.ReadMemStats(&memory)
 
@@ -96,50 +293,195 @@ func (w *Workstation) WorkstationInformation() Info {
 		return b / 1024
 	}
 
-	info.Runtime = RuntimeInfo{
+	return RuntimeInfo{
 		Uptime:      time.Since(w.startedAt).Seconds(),
 		MemoryAlloc: kb(memory.Alloc),
 		Gorutines:   runtime.NumGoroutine(),
 		NumGC:       memory.NumGC,
 	}
+}
 
+// Information returns a snapshot of the tasks of the workspace
+func (w *Workspace) Information() WorkspaceInfo {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	for _, workspace := range w.spaces {
-		workspaceInfo := WorkspaceInfo{
-			Name:           workspace.worker.Name(),
-			TotalProcesses: workspace.NumberOfActiveAsyncTasks(),
-			Processes:      []ProcessInfo{},
+	info := WorkspaceInfo{
+		Name:          w.worker.Name(),
+		TotalRestarts: w.restarts,
+		Processes:     make([]ProcessInfo, 0, len(w.tasks)),
+		Queued:        []ProcessInfo{},
+		Detached:      []ProcessInfo{},
+		Paused:        w.pausedInformation(),
+	}
+
+	for id, process := range w.tasks {
+		processInfo := w.processInformation(id, process)
+		if process.state == TaskQueued {
+			info.Queued = append(info.Queued, processInfo)
+			continue
 		}
 
-		for id, process := range workspace.tasks {
-			workspaceInfo.Processes = append(workspaceInfo.Processes, ProcessInfo{
-				StartedAt: process.startedAt.Format("2006-01-02 15:04:05"),
-				Name:      fmt.Sprintf("%s%s", workspace.worker.Label(), id),
-			})
+		info.Processes = append(info.Processes, processInfo)
+	}
+
+	info.TotalProcesses = len(info.Processes)
+	info.TotalQueued = len(info.Queued)
+
+	for id, h := range w.histories {
+		if _, ok := w.tasks[id]; ok {
+			continue
 		}
 
-		sort.Slice(workspaceInfo.Processes, func(i, j int) bool {
-			return workspaceInfo.Processes[i].StartedAt > workspaceInfo.Processes[j].StartedAt
-		})
+		info.Detached = append(info.Detached, w.detachedInformation(id, h))
+	}
 
-		info.Workspaces[workspace.worker.Name()] = workspaceInfo
+	for i := range info.Processes {
+		if info.Processes[i].State == TaskFailed {
+			info.TotalFailed++
+		}
+	}
+
+	for i := range info.Detached {
+		if info.Detached[i].State == TaskFailed {
+			info.TotalFailed++
+		}
 	}
 
+	sort.Slice(info.Processes, func(i, j int) bool {
+		return info.Processes[i].StartedAt > info.Processes[j].StartedAt
+	})
+
+	sort.Slice(info.Queued, func(i, j int) bool {
+		left, right := w.tasks[info.Queued[i].ID], w.tasks[info.Queued[j].ID]
+		if left.priority != right.priority {
+			return left.priority > right.priority
+		}
+
+		return left.queuedAt.Before(right.queuedAt)
+	})
+
+	sort.Slice(info.Detached, func(i, j int) bool {
+		return info.Detached[i].ID < info.Detached[j].ID
+	})
+
 	return info
 }
 
+// TaskInformation returns a snapshot of the task from the pool, or of the detached task from the history
+func (w *Workspace) TaskInformation(id string) (ProcessInfo, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if process, ok := w.tasks[id]; ok {
+		return w.processInformation(id, process), true
+	}
+
+	if h, ok := w.histories[id]; ok {
+		return w.detachedInformation(id, h), true
+	}
+
+	return ProcessInfo{}, false
+}
+
+func (w *Workspace) processInformation(id string, process *Process) ProcessInfo {
+	restarts := 0
+	if process.attempts > 1 {
+		restarts = process.attempts - 1
+	}
+
+	return ProcessInfo{
+		ID:         id,
+		StartedAt:  Datetime(process.startedAt),
+		LaunchedAt: Datetime(process.launchedAt),
+		Name:       w.processName(id),
+		State:      process.state,
+		Priority:   process.priority,
+		Labels:     Labels(process.stream),
+		Attempts:   process.attempts,
+		Restarts:   restarts,
+		History:    w.histories[id].list(),
+		Resources:  process.usage,
+	}
+}
+
+func (w *Workspace) detachedInformation(id string, h *history) ProcessInfo {
+	last := h.last()
+
+	return ProcessInfo{
+		ID:       id,
+		Name:     w.processName(id),
+		State:    last.State,
+		Attempts: last.Attempt,
+		History:  h.list(),
+	}
+}
+
+func (w *Workspace) processName(id string) string {
+	return fmt.Sprintf("%s%s", w.worker.Label(), id)
+}
+
 func (w *Workstation) Drop() error {
-	for _, space := range w.spaces {
-		if err := space.Drop(); err != nil {
-			log.Warning(err)
+	timeout := w.options.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = shutdownWaitDuration
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	reports, err := w.DropContext(ctx)
+	if err != nil {
+		for _, report := range reports {
+			if !report.Clean() {
+				log.Warning(errorless.Labeled(report.Workspace,
+					fmt.Sprintf("%s, unfinished tasks %v, killed PIDs %v", err, report.Unfinished, report.Killed),
+				))
+			}
 		}
 	}
 
 	return nil
 }
 
+// DropContext stops all workspaces simultaneously with the common deadline of the context,
+// and returns the reports of each workspace
+func (w *Workstation) DropContext(ctx context.Context) (map[string]DropReport, error) {
+	w.mu.RLock()
+	spaces := make([]*Workspace, 0, len(w.spaces))
+	for _, space := range w.spaces {
+		spaces = append(spaces, space)
+	}
+	w.mu.RUnlock()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failure error
+		reports = make(map[string]DropReport, len(spaces))
+	)
+
+	for _, space := range spaces {
+		wg.Add(1)
+		go func(space *Workspace) {
+			defer wg.Done()
+
+			report, err := space.DropContext(ctx)
+
+			mu.Lock()
+			reports[space.Name()] = report
+			if err != nil {
+				failure = err
+			}
+			mu.Unlock()
+		}(space)
+	}
+
+	wg.Wait()
+
+	return reports, failure
+}
+
 func (w *Workstation) DropMsg() string {
 	return "glance completed successfully"
 }
@@ -3,9 +3,14 @@ package glance
 import (
 	"context"
 	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/zikwall/glance/pkg/proc"
 	"github.com/zikwall/glance/pkg/workers/errorless"
 )
 
@@ -142,3 +147,1173 @@ func TestNewWorkstation(t *testing.T) {
 		})
 	})
 }
+
+type FlappingWorker struct {
+	MockWorker
+}
+
+func (w *FlappingWorker) Perform(_ context.Context, _ WorkerStream) {}
+
+func (w *FlappingWorker) Name() string {
+	return "flapping_worker"
+}
+
+func TestRestartPolicy(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workspace := NewWorkspaceWithOptions(ctx, &FlappingWorker{}, &WorkspaceOptions{
+		RestartPolicy: RestartPolicy{
+			MaxRetries:     2,
+			InitialBackoff: time.Millisecond * 10,
+			MaxBackoff:     time.Millisecond * 20,
+			CoolDown:       time.Millisecond * 200,
+		},
+	})
+
+	t.Run("it should be restart died task", func(t *testing.T) {
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		info := workspace.Information()
+		if len(info.Processes) != 1 {
+			t.Fatal("Fail, expect task in cool-down is still in pool")
+		}
+
+		if info.Processes[0].Attempts != 3 || info.Processes[0].Restarts != 2 {
+			t.Fatalf("Fail, expect 3 attempts and 2 restarts, give %d and %d",
+				info.Processes[0].Attempts, info.Processes[0].Restarts,
+			)
+		}
+
+		<-time.After(time.Millisecond * 300)
+
+		if workspace.NumberOfActiveAsyncTasks() != 0 {
+			t.Fatal("Fail, expect given up task is removed from pool")
+		}
+	})
+
+	t.Run("it should be calculate exponential backoff", func(t *testing.T) {
+		policy := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second * 5}
+
+		if delay := policy.Backoff(1); delay != time.Second {
+			t.Fatalf("Fail, expect 1s give %s", delay)
+		}
+
+		if delay := policy.Backoff(3); delay != time.Second*4 {
+			t.Fatalf("Fail, expect 4s give %s", delay)
+		}
+
+		if delay := policy.Backoff(10); delay != time.Second*5 {
+			t.Fatalf("Fail, expect 5s give %s", delay)
+		}
+	})
+}
+
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return "exit status 1"
+}
+
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+type FailingWorker struct {
+	MockWorker
+}
+
+func (w *FailingWorker) PerformWithExit(_ context.Context, _ WorkerStream) error {
+	return &exitError{code: 1}
+}
+
+func TestTaskStateHistory(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workspace := NewWorkspaceWithOptions(ctx, &FailingWorker{}, &WorkspaceOptions{HistorySize: 3})
+
+	t.Run("it should be keep history of failed task", func(t *testing.T) {
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		info := workspace.Information()
+		if len(info.Processes) != 0 || len(info.Detached) != 1 {
+			t.Fatal("Fail, expect one detached task")
+		}
+
+		detached := info.Detached[0]
+		if detached.State != TaskFailed {
+			t.Fatalf("Fail, expect failed state, give %s", detached.State)
+		}
+
+		last := detached.History[len(detached.History)-1]
+		if last.ExitCode != 1 || last.Error == "" {
+			t.Fatalf("Fail, expect exit code 1 and error text, give %d '%s'", last.ExitCode, last.Error)
+		}
+	})
+
+	t.Run("it should be bound history size", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+				t.Fatal(err)
+			}
+
+			<-time.After(time.Millisecond * 50)
+		}
+
+		if history := workspace.Information().Detached[0].History; len(history) != 3 {
+			t.Fatalf("Fail, expect 3 transitions, give %d", len(history))
+		}
+	})
+
+	t.Run("it should be mark task stopped by scheduler", func(t *testing.T) {
+		mock := NewWorkspace(ctx, &MockWorker{})
+		if err := mock.PerformAsync(MockWorkerStream{"2", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if state := mock.Information().Processes[0].State; state != TaskStarting && state != TaskRunning {
+			t.Fatalf("Fail, expect running task, give %s", state)
+		}
+
+		if err := mock.FinishAsyncTask("2"); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		if state := mock.Information().Detached[0].State; state != TaskStoppedByScheduler {
+			t.Fatalf("Fail, expect stopped by scheduler state, give %s", state)
+		}
+	})
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workspace := NewWorkspaceWithOptions(ctx, &MockWorker{}, &WorkspaceOptions{MaxConcurrentTasks: 1})
+
+	t.Run("it should be queue tasks over the limit", func(t *testing.T) {
+		streams := []WorkerItem{
+			{ID: "1", URL: "in"},
+			{ID: "2", URL: "in"},
+			{ID: "3", URL: "in", Priority: 5},
+		}
+
+		for _, stream := range streams {
+			if err := workspace.PerformAsync(stream); err != nil {
+				t.Fatal(err)
+			}
+
+			<-time.After(time.Millisecond * 20)
+		}
+
+		info := workspace.Information()
+		if info.TotalProcesses != 1 || info.TotalQueued != 2 {
+			t.Fatalf("Fail, expect 1 running and 2 queued tasks, give %d and %d", info.TotalProcesses, info.TotalQueued)
+		}
+
+		if info.Queued[0].ID != "3" || info.Queued[1].ID != "2" {
+			t.Fatal("Fail, expect prioritized task first in queue")
+		}
+
+		if err := workspace.FinishAsyncTask("1"); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		info = workspace.Information()
+		if info.TotalProcesses != 1 || info.Processes[0].ID != "3" {
+			t.Fatal("Fail, expect prioritized task is admitted")
+		}
+
+		if err := workspace.FinishAsyncTask("2"); err != nil {
+			t.Fatal(err)
+		}
+
+		if workspace.NumberOfQueuedAsyncTasks() != 0 {
+			t.Fatal("Fail, expect empty queue")
+		}
+	})
+}
+
+func TestRuntimeRegistration(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workstation := New(ctx, &MockWorker{})
+
+	t.Run("it should be register worker at runtime", func(t *testing.T) {
+		workspace, err := workstation.Register(&FlappingWorker{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := workstation.Register(&FlappingWorker{}, nil); err == nil {
+			t.Fatal("Fail, expect error for duplicated workspace")
+		}
+
+		if _, ok := workstation.WorkstationInformation().Workspaces["flapping_worker"]; !ok {
+			t.Fatal("Fail, expect registered workspace in information")
+		}
+
+		if workspace.Name() != "flapping_worker" {
+			t.Fatalf("Fail, expect flapping_worker give %s", workspace.Name())
+		}
+	})
+
+	t.Run("it should be drain and unregister worker", func(t *testing.T) {
+		workspace, err := workstation.Workspace("mock_worker")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := workstation.Unregister("mock_worker"); err != nil {
+			t.Fatal(err)
+		}
+
+		if workspace.NumberOfActiveAsyncTasks() != 0 {
+			t.Fatal("Fail, expect drained workspace")
+		}
+
+		if _, err := workstation.Workspace("mock_worker"); err == nil {
+			t.Fatal("Fail, expect unregistered workspace")
+		}
+
+		if err := workspace.PerformAsync(MockWorkerStream{"2", "in"}); !errors.Is(err, ErrorWorkspaceIsDrained) {
+			t.Fatal("Fail, expect drained workspace error")
+		}
+	})
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workstation := NewWithOptions(ctx, &WorkspaceOptions{
+		RestartPolicy: RestartPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond},
+	}, &FailingWorker{})
+
+	events, unsubscribe := workstation.Subscribe(10)
+	second, unsubscribeSecond := workstation.Subscribe(10)
+	defer unsubscribeSecond()
+
+	workspace, err := workstation.Workspace("mock_worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should be publish lifecycle events to all subscribers", func(t *testing.T) {
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []EventType{
+			EventTaskStarted,
+			EventTaskExited,
+			EventRestartScheduled,
+			EventTaskStarted,
+			EventTaskExited,
+		}
+
+		for _, channel := range []<-chan Event{events, second} {
+			for _, eventType := range expected {
+				select {
+				case event := <-channel:
+					if event.Type != eventType || event.StreamID != "1" || event.Workspace != "mock_worker" {
+						t.Fatalf("Fail, expect %s event, give %s", eventType, event.Type)
+					}
+
+					if event.Type == EventTaskExited && event.ExitCode != 1 {
+						t.Fatalf("Fail, expect exit code 1, give %d", event.ExitCode)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("Fail, expect %s event", eventType)
+				}
+			}
+		}
+
+		unsubscribe()
+
+		if _, ok := <-events; ok {
+			t.Fatal("Fail, expect closed channel after unsubscribe")
+		}
+	})
+
+	t.Run("it should be publish workspace drained event", func(t *testing.T) {
+		if err := workstation.Unregister("mock_worker"); err != nil {
+			t.Fatal(err)
+		}
+
+		for {
+			select {
+			case event := <-second:
+				if event.Type == EventWorkspaceDrained {
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Fail, expect workspace drained event")
+			}
+		}
+	})
+}
+
+type PanickingWorker struct {
+	MockWorker
+}
+
+func (w *PanickingWorker) Perform(_ context.Context, _ WorkerStream) {
+	panic("unexpected worker failure")
+}
+
+func TestPanicIsolation(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workspace := NewWorkspaceWithOptions(ctx, &PanickingWorker{}, &WorkspaceOptions{
+		RestartPolicy: RestartPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond},
+	})
+
+	events, unsubscribe := workspace.Subscribe(10)
+	defer unsubscribe()
+
+	t.Run("it should be recover panic and restart task", func(t *testing.T) {
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		crashes := 0
+		for crashes < 2 {
+			select {
+			case event := <-events:
+				if event.Type != EventTaskCrashed {
+					continue
+				}
+
+				if event.Stack == "" {
+					t.Fatal("Fail, expect stack trace of crash")
+				}
+
+				crashes++
+			case <-time.After(time.Second):
+				t.Fatal("Fail, expect two crash events")
+			}
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		info, ok := workspace.TaskInformation("1")
+		if !ok || info.State != TaskFailed {
+			t.Fatal("Fail, expect failed task after exhausted restarts")
+		}
+
+		crashed := 0
+		for _, transition := range info.History {
+			if transition.State == TaskCrashed {
+				crashed++
+			}
+		}
+
+		if crashed != 2 {
+			t.Fatalf("Fail, expect 2 crashes in history, give %d", crashed)
+		}
+	})
+}
+
+type StuckWorker struct {
+	MockWorker
+	child *mockChild
+}
+
+type mockChild struct {
+	killed chan struct{}
+}
+
+func (c *mockChild) Pid() int {
+	return 42
+}
+
+func (c *mockChild) Kill() error {
+	close(c.killed)
+	return nil
+}
+
+// Perform ignores the cancellation of the context, until its child process is killed
+func (w *StuckWorker) Perform(ctx context.Context, _ WorkerStream) {
+	untrack := TrackProcess(ctx, w.child)
+	defer untrack()
+
+	<-w.child.killed
+}
+
+func TestDropContext(t *testing.T) {
+	worker := &StuckWorker{child: &mockChild{killed: make(chan struct{})}}
+	workspace := NewWorkspace(context.Background(), worker)
+
+	if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 20)
+
+	t.Run("it should be kill child processes after deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		report, err := workspace.DropContext(ctx)
+		if !errors.Is(err, ErrorShutdownWithoutGracefulCompletion) {
+			t.Fatal("Fail, expect shutdown without graceful completion")
+		}
+
+		if len(report.Unfinished) != 1 || report.Unfinished[0] != "1" {
+			t.Fatalf("Fail, expect unfinished task #1, give %v", report.Unfinished)
+		}
+
+		if len(report.Killed) != 1 || report.Killed[0] != 42 {
+			t.Fatalf("Fail, expect killed PID 42, give %v", report.Killed)
+		}
+	})
+
+	t.Run("it should be kill and report tasks removed from the pool", func(t *testing.T) {
+		detached := NewWorkspace(context.Background(), &StuckWorker{child: &mockChild{killed: make(chan struct{})}})
+		if err := detached.PerformAsync(MockWorkerStream{"2", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 20)
+
+		if err := detached.FinishAsyncTask("2"); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		report, err := detached.DropContext(ctx)
+		if !errors.Is(err, ErrorShutdownWithoutGracefulCompletion) || report.Clean() {
+			t.Fatalf("Fail, expect unclean shutdown, give %v", err)
+		}
+
+		if len(report.Unfinished) != 1 || report.Unfinished[0] != "2" || len(report.Killed) != 1 {
+			t.Fatalf("Fail, expect killed task #2, give %v %v", report.Unfinished, report.Killed)
+		}
+	})
+
+	t.Run("it should be safe to drop multiple times", func(t *testing.T) {
+		<-time.After(time.Millisecond * 50)
+
+		if err := workspace.Drop(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := workspace.Drop(); err != nil {
+			t.Fatal(err)
+		}
+
+		if info, _ := workspace.TaskInformation("1"); info.State != TaskStoppedByShutdown {
+			t.Fatalf("Fail, expect stopped by shutdown state, give %s", info.State)
+		}
+	})
+}
+
+type SilentWorker struct {
+	MockWorker
+}
+
+func (w *SilentWorker) Heartbeats() bool {
+	return true
+}
+
+// Perform sends a single heartbeat and hangs without output until it is recycled
+func (w *SilentWorker) Perform(ctx context.Context, _ WorkerStream) {
+	Heartbeat(ctx)
+	<-ctx.Done()
+}
+
+type MockStallStorage struct {
+	stalls chan Stall
+}
+
+func (s *MockStallStorage) ProcessStall(stall *Stall) error {
+	s.stalls <- *stall
+	return nil
+}
+
+func TestWatchdog(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	storage := &MockStallStorage{stalls: make(chan Stall, 10)}
+	workspace := NewWorkspaceWithOptions(ctx, &SilentWorker{}, &WorkspaceOptions{
+		RestartPolicy: RestartPolicy{MaxRetries: 5, InitialBackoff: time.Millisecond},
+		StallTimeout:  time.Millisecond * 50,
+		StallStorage:  storage,
+	})
+
+	events, unsubscribe := workspace.Subscribe(10)
+	defer unsubscribe()
+
+	t.Run("it should be recycle stalled task", func(t *testing.T) {
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case stall := <-storage.stalls:
+			if stall.StreamID != "1" || stall.Silence < 0.05 {
+				t.Fatalf("Fail, expect stall of task #1, give %+v", stall)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Fail, expect stall in storage")
+		}
+
+		stalled := false
+		for !stalled {
+			select {
+			case event := <-events:
+				stalled = event.Type == EventTaskStalled
+			case <-time.After(time.Second):
+				t.Fatal("Fail, expect stalled event")
+			}
+		}
+
+		<-time.After(time.Millisecond * 20)
+
+		info, ok := workspace.TaskInformation("1")
+		if !ok || info.Attempts < 2 {
+			t.Fatal("Fail, expect recycled task in pool")
+		}
+
+		if err := workspace.FinishAsyncTask("1"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("it should be not recycle tasks of workers without heartbeats", func(t *testing.T) {
+		silent := NewWorkspaceWithOptions(ctx, &MockWorker{}, &WorkspaceOptions{StallTimeout: time.Millisecond * 20, StallStorage: storage})
+		if err := silent.PerformAsync(MockWorkerStream{"2", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		if info, ok := silent.TaskInformation("2"); !ok || info.Attempts != 1 {
+			t.Fatal("Fail, expect task without recycling")
+		}
+	})
+}
+
+type TrackingWorker struct {
+	MockWorker
+}
+
+func (w *TrackingWorker) Perform(ctx context.Context, _ WorkerStream) {
+	untrack := TrackProcess(ctx, &mockChild{})
+	defer untrack()
+
+	<-ctx.Done()
+}
+
+func TestResourceAccounting(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	sampler := func(pid int) (proc.Usage, error) {
+		if pid != 42 {
+			return proc.Usage{}, errors.New("unknown process")
+		}
+
+		return proc.Usage{CPUTime: time.Second, RSS: 100 << 20, ReadBytes: 1024}, nil
+	}
+
+	t.Run("it should be expose resources of child processes", func(t *testing.T) {
+		workspace := NewWorkspaceWithOptions(ctx, &TrackingWorker{}, &WorkspaceOptions{
+			Resources: &ResourceOptions{Interval: time.Millisecond * 10, MaxRSS: 50 << 20, Sampler: sampler},
+		})
+
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		info, ok := workspace.TaskInformation("1")
+		if !ok || info.Resources == nil {
+			t.Fatal("Fail, expect resources of task #1")
+		}
+
+		if info.Resources.RSS != 100<<20 || info.Resources.CPUTime != 1 || info.Resources.ReadBytes != 1024 {
+			t.Fatalf("Fail, give %+v", info.Resources)
+		}
+
+		if info.Attempts != 1 {
+			t.Fatal("Fail, expect task is not killed without KillOverBudget")
+		}
+	})
+
+	t.Run("it should be recycle task over budget", func(t *testing.T) {
+		workspace := NewWorkspaceWithOptions(ctx, &TrackingWorker{}, &WorkspaceOptions{
+			RestartPolicy: RestartPolicy{MaxRetries: 5, InitialBackoff: time.Millisecond},
+			Resources: &ResourceOptions{
+				Interval:       time.Millisecond * 10,
+				MaxRSS:         50 << 20,
+				KillOverBudget: true,
+				Sampler:        sampler,
+			},
+		})
+
+		events, unsubscribe := workspace.Subscribe(10)
+		defer unsubscribe()
+
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for {
+			select {
+			case event := <-events:
+				if event.Type != EventTaskOverBudget {
+					continue
+				}
+
+				var exceeded *errorless.ResourceBudgetExceededError
+				if !errors.As(event.Error, &exceeded) || exceeded.ID != "1" {
+					t.Fatalf("Fail, expect budget error, give %v", event.Error)
+				}
+
+				return
+			case <-time.After(time.Second):
+				t.Fatal("Fail, expect over budget event")
+			}
+		}
+	})
+}
+
+func TestStateFile(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	file := NewStateFile(filepath.Join(t.TempDir(), "state.json"))
+
+	t.Run("it should be load empty state without file", func(t *testing.T) {
+		state, err := file.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(state.Workspaces) != 0 {
+			t.Fatal("Fail, expect empty state")
+		}
+	})
+
+	t.Run("it should be resume tasks with restart counters", func(t *testing.T) {
+		workspace := NewWorkspaceWithOptions(ctx, &FailingWorker{}, &WorkspaceOptions{
+			RestartPolicy: RestartPolicy{MaxRetries: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+		})
+
+		if err := workspace.PerformAsync(WorkerItem{ID: "1", URL: "in", Priority: 5}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		if err := file.Save(workspace); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := file.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshots := state.Workspaces[workspace.Name()]
+		if len(snapshots) != 1 || snapshots[0].URL != "in" || snapshots[0].Priority != 5 {
+			t.Fatalf("Fail, expect saved task #1, give %+v", snapshots)
+		}
+
+		if snapshots[0].State != TaskBackingOff || snapshots[0].Failures != 1 || snapshots[0].RetryAt == nil {
+			t.Fatalf("Fail, expect backoff state of task #1, give %+v", snapshots[0])
+		}
+
+		resumed := NewWorkspaceWithOptions(ctx, &FailingWorker{}, &WorkspaceOptions{
+			RestartPolicy: RestartPolicy{MaxRetries: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+		})
+
+		restored, err := resumed.Restore(snapshots)
+		if err != nil || restored != 1 {
+			t.Fatalf("Fail, expect one restored task, give %d %v", restored, err)
+		}
+
+		<-time.After(time.Millisecond * 50)
+
+		info, ok := resumed.TaskInformation("1")
+		if !ok || info.State != TaskBackingOff || info.Attempts != 1 {
+			t.Fatalf("Fail, expect resumed task waiting for the rest of backoff, give %+v", info)
+		}
+	})
+	t.Run("it should be omit retry time of running tasks", func(t *testing.T) {
+		workspace := NewWorkspace(ctx, &MockWorker{})
+		if err := workspace.PerformAsync(WorkerItem{ID: "2", URL: "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "running.json")
+		if err := NewStateFile(path).Save(workspace); err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(content), "retry_at") {
+			t.Fatalf("Fail, expect snapshot without retry time, give %s", content)
+		}
+	})
+}
+
+func TestLaunchRate(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	t.Run("it should be limit launches of workspace", func(t *testing.T) {
+		workspace := NewWorkspaceWithOptions(ctx, &MockWorker{}, &WorkspaceOptions{LaunchRate: 20})
+
+		events, unsubscribe := workspace.Subscribe(10)
+		defer unsubscribe()
+
+		startedAt := time.Now()
+		for _, id := range []string{"1", "2", "3", "4", "5"} {
+			if err := workspace.PerformAsync(MockWorkerStream{id, "in"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for started := 0; started < 5; {
+			select {
+			case event := <-events:
+				if event.Type == EventTaskStarted {
+					started++
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Fail, expect started tasks")
+			}
+		}
+
+		if elapsed := time.Since(startedAt); elapsed < time.Millisecond*150 {
+			t.Fatalf("Fail, expect launches spread over time, give %s", elapsed)
+		}
+	})
+
+	t.Run("it should be limit launches per host", func(t *testing.T) {
+		limiter := newLaunchLimiter(&WorkspaceOptions{HostLaunchRate: 1, HostLaunchBurst: 1})
+
+		if !limiter.wait(ctx, "rtmp://a.example/live/1") || !limiter.wait(ctx, "rtmp://b.example/live/1") {
+			t.Fatal("Fail, expect first launches to different hosts are allowed at once")
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+
+		if limiter.wait(waitCtx, "rtmp://a.example/live/2") {
+			t.Fatal("Fail, expect second launch to the same host is delayed")
+		}
+	})
+
+	t.Run("it should be keep the token of the bucket while the launch waits for the other one", func(t *testing.T) {
+		limiter := newLaunchLimiter(&WorkspaceOptions{LaunchRate: 1, LaunchBurst: 2, HostLaunchRate: 1, HostLaunchBurst: 1})
+		host := limiter.host("rtmp://a.example/live/1")
+		buckets := []*tokenBucket{limiter.workspace, host}
+
+		now := time.Now()
+		if limiter.take(buckets, now) != 0 {
+			t.Fatal("Fail, expect first launch is allowed at once")
+		}
+
+		if limiter.take(buckets, now) == 0 {
+			t.Fatal("Fail, expect second launch to the same host is delayed")
+		}
+
+		if limiter.take([]*tokenBucket{limiter.workspace}, now) != 0 {
+			t.Fatal("Fail, expect the token of the workspace is not taken by the delayed launch")
+		}
+	})
+
+	t.Run("it should be forget the hosts whose buckets are refilled", func(t *testing.T) {
+		limiter := newLaunchLimiter(&WorkspaceOptions{HostLaunchRate: 100, HostLaunchBurst: 1})
+
+		if !limiter.wait(ctx, "rtmp://a.example/live/1") {
+			t.Fatal("Fail, expect launch is allowed")
+		}
+
+		time.Sleep(time.Millisecond * 20)
+		limiter.host("rtmp://b.example/live/1")
+
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+
+		if _, ok := limiter.hosts["a.example"]; ok || len(limiter.hosts) != 1 {
+			t.Fatalf("Fail, expect only the bucket of the last host, give %d buckets", len(limiter.hosts))
+		}
+	})
+}
+
+func TestStreamDescriptor(t *testing.T) {
+	stream := WorkerItem{
+		ID:      "1",
+		URL:     "in",
+		Labels:  map[string]string{"tier": "premium", "tenant": "acme"},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Options: map[string]string{"terminate_timeout": "10s"},
+	}
+
+	t.Run("it should be read descriptor of stream", func(t *testing.T) {
+		if Labels(stream)["tenant"] != "acme" || Headers(stream)["Authorization"] != "Bearer token" {
+			t.Fatal("Fail, expect labels and headers of stream")
+		}
+
+		if value, ok := StreamOption(stream, "terminate_timeout"); !ok || value != "10s" {
+			t.Fatal("Fail, expect option of stream")
+		}
+
+		if Labels(MockWorkerStream{"1", "in"}) != nil {
+			t.Fatal("Fail, expect no labels of plain stream")
+		}
+
+		if _, ok := StreamOption(MockWorkerStream{"1", "in"}, "terminate_timeout"); ok {
+			t.Fatal("Fail, expect no options of plain stream")
+		}
+	})
+
+	t.Run("it should be sort label pairs", func(t *testing.T) {
+		names, values := LabelPairs(stream.Labels)
+		if len(names) != 2 || names[0] != "tenant" || values[0] != "acme" || names[1] != "tier" || values[1] != "premium" {
+			t.Fatalf("Fail, give %v %v", names, values)
+		}
+	})
+
+	t.Run("it should be expose labels of task", func(t *testing.T) {
+		ctx, cancelFunc := context.WithCancel(context.Background())
+		defer cancelFunc()
+
+		workspace := NewWorkspace(ctx, &MockWorker{})
+		if err := workspace.PerformAsync(stream); err != nil {
+			t.Fatal(err)
+		}
+
+		info, ok := workspace.TaskInformation("1")
+		if !ok || info.Labels["tier"] != "premium" {
+			t.Fatalf("Fail, expect labels of task, give %+v", info)
+		}
+
+		snapshots := workspace.Snapshot()
+		if len(snapshots) != 1 || snapshots[0].Headers["Authorization"] != "Bearer token" {
+			t.Fatalf("Fail, expect descriptor in snapshot, give %+v", snapshots)
+		}
+	})
+}
+
+func TestStreamChanges(t *testing.T) {
+	stream := WorkerItem{ID: "1", URL: "in", Headers: map[string]string{"Authorization": "1"}}
+
+	t.Run("it should be detect changes of descriptor", func(t *testing.T) {
+		if stream.Changed(WorkerItem{ID: "1", URL: "in", Headers: map[string]string{"Authorization": "1"}, Priority: 10}) {
+			t.Fatal("Fail, expect the priority does not require the restart")
+		}
+
+		if !stream.Changed(WorkerItem{ID: "1", URL: "out", Headers: map[string]string{"Authorization": "1"}}) {
+			t.Fatal("Fail, expect changed URL")
+		}
+
+		if !stream.Changed(WorkerItem{ID: "1", URL: "in", Headers: map[string]string{"Authorization": "2"}}) {
+			t.Fatal("Fail, expect changed headers")
+		}
+
+		changed := WorkerItem{ID: "1", URL: "in", Headers: map[string]string{"Authorization": "1"}, Labels: map[string]string{"a": "b"}}
+		if !stream.Changed(changed) {
+			t.Fatal("Fail, expect changed labels")
+		}
+	})
+
+	t.Run("it should be restart task with new descriptor", func(t *testing.T) {
+		ctx, cancelFunc := context.WithCancel(context.Background())
+		defer cancelFunc()
+
+		workspace := NewWorkspace(ctx, &MockWorker{})
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if workspace.ActiveTasks().Streams["1"].URL != "1" {
+			t.Fatal("Fail, expect URL in active tasks")
+		}
+
+		if err := workspace.RestartAsyncTask(WorkerItem{ID: "1", URL: "out"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if workspace.ActiveTasks().Streams["1"].URL != "out" {
+			t.Fatal("Fail, expect new URL in active tasks")
+		}
+
+		if err := workspace.RestartAsyncTask(WorkerItem{ID: "2", URL: "out"}); err == nil {
+			t.Fatal("Fail, expect error for unknown task")
+		}
+	})
+}
+
+// SlowTerminatingWorker needs time to stop its children after cancellation and counts its overlapping runs
+type SlowTerminatingWorker struct {
+	MockWorker
+	running  int32
+	overlaps int32
+}
+
+func (w *SlowTerminatingWorker) Perform(ctx context.Context, _ WorkerStream) {
+	if atomic.AddInt32(&w.running, 1) > 1 {
+		atomic.AddInt32(&w.overlaps, 1)
+	}
+	defer atomic.AddInt32(&w.running, -1)
+
+	<-ctx.Done()
+	<-time.After(time.Millisecond * 50)
+}
+
+func TestPause(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	workspace := NewWorkspace(ctx, &MockWorker{})
+	if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("it should be pause task and refuse its start", func(t *testing.T) {
+		if err := workspace.Pause("1", "maintenance", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if workspace.lookupAsyncTask("1") || !workspace.IsPaused("1") {
+			t.Fatal("Fail, expect paused task is removed from pool")
+		}
+
+		var paused *errorless.TaskPausedError
+		if err := workspace.PerformAsync(MockWorkerStream{"1", "in"}); !errors.As(err, &paused) {
+			t.Fatalf("Fail, expect paused error, give %v", err)
+		}
+
+		info := workspace.Information()
+		if len(info.Paused) != 1 || info.Paused[0].Reason != "maintenance" || info.Paused[0].Until != "" {
+			t.Fatalf("Fail, expect paused task in information, give %+v", info.Paused)
+		}
+	})
+
+	t.Run("it should be resume task", func(t *testing.T) {
+		if err := workspace.Resume("1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if !workspace.lookupAsyncTask("1") || workspace.IsPaused("1") {
+			t.Fatal("Fail, expect resumed task in pool")
+		}
+	})
+
+	t.Run("it should be resume task after pause expires", func(t *testing.T) {
+		if err := workspace.Pause("1", "maintenance", time.Now().Add(time.Millisecond*50)); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 100)
+
+		if !workspace.lookupAsyncTask("1") || workspace.IsPaused("1") {
+			t.Fatal("Fail, expect task resumed after expiration")
+		}
+	})
+
+	t.Run("it should be keep the pause that replaced the expiring one", func(t *testing.T) {
+		if err := workspace.Pause("1", "maintenance", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		workspace.mu.RLock()
+		expiring := workspace.pauses["1"]
+		workspace.mu.RUnlock()
+
+		if err := workspace.Pause("1", "incident", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the timer of the replaced pause fires right after the repeated pause
+		workspace.expire("1", expiring)
+
+		if workspace.lookupAsyncTask("1") || !workspace.IsPaused("1") {
+			t.Fatal("Fail, expect task stays paused by the repeated pause")
+		}
+
+		if err := workspace.Resume("1"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("it should be start resumed task after the stopped one has exited", func(t *testing.T) {
+		worker := &SlowTerminatingWorker{}
+		slow := NewWorkspace(ctx, worker)
+		if err := slow.PerformAsync(MockWorkerStream{"1", "in"}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 10)
+
+		if err := slow.Pause("1", "maintenance", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := slow.Resume("1"); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(time.Millisecond * 10)
+
+		if atomic.LoadInt32(&worker.overlaps) != 0 || atomic.LoadInt32(&worker.running) != 1 {
+			t.Fatal("Fail, expect only the resumed worker running")
+		}
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	// 2021-06-04 is Friday
+	at := func(value string) time.Time {
+		moment, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return moment
+	}
+
+	t.Run("it should be parse windows", func(t *testing.T) {
+		schedule, err := ParseSchedule("0 18 * * mon-fri 5h30m; 0 22 * * sat,sun 4h", "UTC")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cases := map[string]bool{
+			"2021-06-04 17:59": false,
+			"2021-06-04 18:00": true,
+			"2021-06-04 23:30": false,
+			"2021-06-05 12:00": false,
+			"2021-06-05 23:00": true,
+			"2021-06-06 01:59": true,
+			"2021-06-07 01:59": true,
+			"2021-06-08 01:59": false,
+		}
+
+		for moment, expected := range cases {
+			if schedule.Active(at(moment)) != expected {
+				t.Fatalf("Failed, expect %v at %s", expected, moment)
+			}
+		}
+	})
+
+	t.Run("it should be match days of month or days of week", func(t *testing.T) {
+		schedule, err := ParseSchedule("*/30 12 1,15 * sun 30m", "UTC")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cases := map[string]bool{
+			"2021-06-01 12:10": true,
+			"2021-06-06 12:45": true,
+			"2021-06-04 12:10": false,
+			"2021-06-15 13:00": false,
+		}
+
+		for moment, expected := range cases {
+			if schedule.Active(at(moment)) != expected {
+				t.Fatalf("Failed, expect %v at %s", expected, moment)
+			}
+		}
+	})
+
+	t.Run("it should be apply timezone", func(t *testing.T) {
+		schedule, err := ParseSchedule("0 9 * * * 1h", "Europe/Moscow")
+		if err != nil {
+			t.Skip(err)
+		}
+
+		if !schedule.Active(at("2021-06-04 06:30")) || schedule.Active(at("2021-06-04 09:30")) {
+			t.Fatal("Failed, expect window in Moscow time")
+		}
+	})
+
+	t.Run("it should be reject invalid windows", func(t *testing.T) {
+		expressions := []string{
+			"", "0 18 * * *", "0 18 * * fry 1h", "0 25 * * * 1h", "0 10 * * * 0s", "0 10 * * mon 1h extra", "0 10 * * * 200h", "0 10-9 * * * 1h",
+		}
+
+		for _, expression := range expressions {
+			if _, err := ParseSchedule(expression, "UTC"); err == nil {
+				t.Fatalf("Failed, expect error for %q", expression)
+			}
+		}
+	})
+
+	t.Run("it should be check streams on air", func(t *testing.T) {
+		stream := WorkerItem{ID: "1", Options: map[string]string{OptionSchedule: "0 18 * * fri 2h", OptionTimezone: "UTC"}}
+		if airing, err := OnAir(stream, at("2021-06-04 19:00")); err != nil || !airing {
+			t.Fatalf("Failed, expect stream on air, give %v", err)
+		}
+
+		if airing, _ := OnAir(stream, at("2021-06-04 21:00")); airing {
+			t.Fatal("Failed, expect stream off air")
+		}
+
+		if airing, _ := OnAir(WorkerItem{ID: "2"}, at("2021-06-04 21:00")); !airing {
+			t.Fatal("Failed, expect stream without schedule on air")
+		}
+
+		invalid := WorkerItem{ID: "3", Options: map[string]string{OptionSchedule: "never"}}
+		if airing, err := OnAir(invalid, at("2021-06-04 21:00")); err == nil || !airing {
+			t.Fatal("Failed, expect stream with invalid schedule on air with error")
+		}
+	})
+
+	t.Run("it should be report invalid schedule once per change", func(t *testing.T) {
+		schedules := NewSchedules()
+		invalid := WorkerItem{ID: "3", Options: map[string]string{OptionSchedule: "never"}}
+
+		if airing, err := schedules.OnAir(invalid, at("2021-06-04 21:00")); err == nil || !airing {
+			t.Fatal("Failed, expect error of invalid schedule")
+		}
+
+		if airing, err := schedules.OnAir(invalid, at("2021-06-04 22:00")); err != nil || !airing {
+			t.Fatalf("Failed, expect cached schedule without error, give %v", err)
+		}
+
+		fixed := WorkerItem{ID: "3", Options: map[string]string{OptionSchedule: "0 18 * * fri 2h", OptionTimezone: "UTC"}}
+		if airing, err := schedules.OnAir(fixed, at("2021-06-04 21:00")); err != nil || airing {
+			t.Fatal("Failed, expect changed schedule is parsed again")
+		}
+
+		schedules.Retain(Collection{Streams: map[string]WorkerItem{}})
+		if len(schedules.entries) != 0 {
+			t.Fatal("Failed, expect schedules of missing streams are forgotten")
+		}
+	})
+}